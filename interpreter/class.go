@@ -0,0 +1,87 @@
+package interpreter
+
+import (
+    "fmt"
+    ."github.com/elliotthill/golox/language"
+)
+
+type RuntimeClass struct{
+    callable
+    name string
+    superclass *RuntimeClass
+    methods map[string]RuntimeFunction
+}
+
+//Look for the method on the class then up through its superclasses
+func (class *RuntimeClass) findMethod(name string) (RuntimeFunction, bool) {
+
+    if method, ok := class.methods[name]; ok {
+        return method, true
+    }
+
+    if class.superclass != nil {
+        return class.superclass.findMethod(name)
+    }
+    return RuntimeFunction{}, false
+}
+
+//Calling a class creates an instance and runs init() on it
+func (class *RuntimeClass) call(interp *Interpreter, arguments []interface{}) interface{} {
+
+    instance := NewInstance(class)
+
+    if initializer, ok := class.findMethod("init"); ok {
+        initializer.bind(instance).call(interp, arguments)
+    }
+    return instance
+}
+
+func (class *RuntimeClass) arity() (int, int) {
+
+    if initializer, ok := class.findMethod("init"); ok {
+        return initializer.arity()
+    }
+    return 0, 0
+}
+
+func (class *RuntimeClass) String() string {
+    return class.name
+}
+
+type Instance struct{
+    class *RuntimeClass
+    fields map[string]interface{}
+}
+
+func NewInstance(class *RuntimeClass) *Instance {
+    instance := new(Instance)
+    instance.class = class
+    instance.fields = make(map[string]interface{})
+    return instance
+}
+
+//Fields shadow methods
+func (instance *Instance) Get(name Token) interface{} {
+
+    if value, ok := instance.fields[name.Lexeme]; ok {
+        return value
+    }
+
+    if method, ok := instance.class.findMethod(name.Lexeme); ok {
+        return method.bind(instance)
+    }
+
+    panic(fmt.Sprintf("Undefined property '%s'.", name.Lexeme))
+}
+
+func (instance *Instance) Set(name Token, value interface{}) {
+    instance.fields[name.Lexeme] = value
+}
+
+func (instance *Instance) String() string {
+
+    if instance.class == errorClass {
+        return "Error: " + stringify(instance.fields["message"])
+    }
+    return instance.class.name + " instance"
+}
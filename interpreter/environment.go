@@ -12,10 +12,11 @@ func NewEnvironment(enclosing *Environment) *Environment{
     return environment
 }
 
-func (env Environment) Get(name string) interface{} {
+//Look up name here or in an enclosing scope, ok is false if it was never defined
+func (env Environment) Get(name string) (interface{}, bool) {
 
     if localValue, ok := env.values[name]; ok {
-        return localValue
+        return localValue, true
     }
 
     //Look recursively into parent scope
@@ -23,10 +24,26 @@ func (env Environment) Get(name string) interface{} {
         return env.enclosing.Get(name)
     }
 
-    return nil
-    //panic("Undefined variable '" + name+ "'")
+    return nil, false
 }
 
+//Read a variable the resolver found distance scopes out
+func (env *Environment) GetAt(distance int, name string) interface{} {
+    return env.ancestor(distance).values[name]
+}
+
+func (env *Environment) AssignAt(distance int, name string, value interface{}) {
+    env.ancestor(distance).values[name] = value
+}
+
+func (env *Environment) ancestor(distance int) *Environment {
+
+    environment := env
+    for i := 0; i < distance; i++ {
+        environment = environment.enclosing
+    }
+    return environment
+}
 
 func (env Environment) Define(name string, value interface{}) {
 
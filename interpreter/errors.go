@@ -0,0 +1,85 @@
+package interpreter
+
+import (
+    "fmt"
+)
+
+//Thrown values unwind to the nearest catch in a panic, like return.
+//origin is the error value describing where it was thrown
+type ThrowSignal struct {
+    value interface{}
+    origin *Instance
+}
+
+//An error nothing caught, returned by Interpret
+type RuntimeError struct {
+    Message string
+    Line int
+    Stack []string //Calls in progress, innermost first
+}
+
+func (err RuntimeError) Error() string {
+    return fmt.Sprintf("[line %d] %s", err.Line, err.Message)
+}
+
+//Runtime errors reach scripts as instances of Error with message, line and stack fields
+var errorClass = &RuntimeClass{name: "Error", methods: map[string]RuntimeFunction{}}
+
+//A call to a script function or class, innermost last on interp.frames
+type callFrame struct {
+    callee string
+    line int
+}
+
+func (interp *Interpreter) errorValue(message string) *Instance {
+
+    stack := []interface{}{}
+    for i := len(interp.frames) - 1; i >= 0; i-- {
+        frame := interp.frames[i]
+        stack = append(stack, fmt.Sprintf("%s called on line %d", frame.callee, frame.line))
+    }
+
+    instance := NewInstance(errorClass)
+    instance.fields["message"] = message
+    instance.fields["line"] = float64(interp.line)
+    instance.fields["stack"] = NewRuntimeList(stack)
+    return instance
+}
+
+//Deferred by each call, a runtime error raised inside becomes an error value
+//here while the call's frame is still on the stack
+func (interp *Interpreter) leaveCall() {
+
+    r := recover()
+    if message, ok := r.(string); ok {
+        r = interp.throwSignal(interp.errorValue(message))
+    }
+
+    interp.frames = interp.frames[:len(interp.frames)-1]
+    if r != nil {
+        panic(r)
+    }
+}
+
+//Error values keep where they were made, anything else is located where it is thrown
+func (interp *Interpreter) throwSignal(value interface{}) ThrowSignal {
+
+    if instance, ok := value.(*Instance); ok && instance.class == errorClass {
+        return ThrowSignal{value: value, origin: instance}
+    }
+    return ThrowSignal{value: value, origin: interp.errorValue("Uncaught exception: " + stringify(value))}
+}
+
+func newRuntimeError(origin *Instance) *RuntimeError {
+
+    err := &RuntimeError{Message: stringify(origin.fields["message"])}
+    if line, ok := origin.fields["line"].(float64); ok {
+        err.Line = int(line)
+    }
+    if stack, ok := origin.fields["stack"].(*RuntimeList); ok {
+        for _, frame := range stack.elements {
+            err.Stack = append(err.Stack, stringify(frame))
+        }
+    }
+    return err
+}
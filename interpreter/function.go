@@ -6,7 +6,8 @@ import (
 )
 
 type callable interface{
-    arity() int
+    //Fewest and most arguments accepted, most is -1 with a rest parameter
+    arity() (int, int)
     call(interp *Interpreter, arguments []interface{}) interface{}
 }
 
@@ -14,6 +15,23 @@ type RuntimeFunction struct{
     callable
     declaration Function
     closure *Environment
+    isInitializer bool
+}
+
+//Method bound to instance, this is defined in an environment around the closure
+func (f RuntimeFunction) bind(instance *Instance) RuntimeFunction {
+
+    env := NewEnvironment(f.closure)
+    env.Define("this", instance)
+    return RuntimeFunction{declaration: f.declaration, closure: env, isInitializer: f.isInitializer}
+}
+
+func (f RuntimeFunction) String() string {
+
+    if f.declaration.Name.Lexeme == "" {
+        return "<fn>"
+    }
+    return "<fn " + f.declaration.Name.Lexeme + ">"
 }
 
 func (f RuntimeFunction) call(interp *Interpreter, arguments []interface{}) (returnVal interface{}) {
@@ -26,10 +44,11 @@ func (f RuntimeFunction) call(interp *Interpreter, arguments []interface{}) (ret
             if v, ok := err.(ReturnValue); ok {
 
                 returnVal = v.value
+                if f.isInitializer {
+                    returnVal = f.closure.GetAt(0, "this")
+                }
                 return
             }
-            fmt.Println("ERROR: DID NOT RETURN")
-            fmt.Println(err)
             panic(err)
         }
     }()
@@ -38,15 +57,103 @@ func (f RuntimeFunction) call(interp *Interpreter, arguments []interface{}) (ret
     //callEnv := interp.environment
 
     for i, param := range f.declaration.Params {
-        //interp.environment[param.lexeme] = arguments[i]
-        funcEnv.Define(param.Lexeme, arguments[i])
+        if i < len(arguments) && arguments[i] != (defaultArgument{}) {
+            funcEnv.Define(param.Lexeme, arguments[i])
+        } else {
+            //Missing arguments always have a default, arity and bindNamed ensure it
+            funcEnv.Define(param.Lexeme, interp.evaluateIn(f.declaration.Defaults[i], f.closure))
+        }
+    }
+
+    if f.declaration.Rest != nil {
+        rest := []interface{}{}
+        if len(arguments) > len(f.declaration.Params) {
+            rest = append(rest, arguments[len(f.declaration.Params):]...)
+        }
+        funcEnv.Define(f.declaration.Rest.Lexeme, NewRuntimeList(rest))
     }
 
     interp.executeBlock(f.declaration.Body, funcEnv)
+
+    //init() always hands back the new instance
+    if f.isInitializer {
+        return f.closure.GetAt(0, "this")
+    }
     return nil
 
 }
 
-func (f RuntimeFunction) arity() int {
-   return len(f.declaration.Params)
+func (f RuntimeFunction) arity() (int, int) {
+
+    min := 0
+    for min < len(f.declaration.Params) && f.declaration.Defaults[min] == nil {
+        min++
+    }
+
+    if f.declaration.Rest != nil {
+        return min, -1
+    }
+    return min, len(f.declaration.Params)
+}
+
+//Stands in for a parameter skipped by named arguments
+type defaultArgument struct{}
+
+//Place named arguments in the slots of the parameters they name
+func bindNamed(fn callable, arguments []interface{}, named []NamedArgument, values []interface{}) []interface{} {
+
+    var declaration Function
+    switch f := fn.(type) {
+    case RuntimeFunction:
+        declaration = f.declaration
+    case *RuntimeClass:
+        if initializer, ok := f.findMethod("init"); ok {
+            declaration = initializer.declaration
+        }
+    default:
+        panic(fmt.Sprintf("%s doesn't accept named arguments.", stringify(fn)))
+    }
+
+    bound := append([]interface{}{}, arguments...)
+    given := len(arguments)
+
+    for n, argument := range named {
+        slot := -1
+        for i, param := range declaration.Params {
+            if param.Lexeme == argument.Name.Lexeme {
+                slot = i
+            }
+        }
+
+        if slot < 0 {
+            panic(fmt.Sprintf("%s has no parameter named '%s'.", stringify(fn), argument.Name.Lexeme))
+        }
+        if slot < given {
+            panic(fmt.Sprintf("Parameter '%s' was given more than once.", argument.Name.Lexeme))
+        }
+
+        for len(bound) <= slot {
+            bound = append(bound, defaultArgument{})
+        }
+        bound[slot] = values[n]
+    }
+
+    //Skipped parameters must have a default to fall back on
+    for i := given; i < len(bound); i++ {
+        if _, skipped := bound[i].(defaultArgument); skipped && declaration.Defaults[i] == nil {
+            panic(fmt.Sprintf("Missing argument for parameter '%s'.", declaration.Params[i].Lexeme))
+        }
+    }
+    return bound
+}
+
+func arityError(min int, max int, got int) string {
+
+    if min == max {
+        return fmt.Sprintf("Expected %d arguments but got %d.", min, got)
+    }
+    if max < 0 {
+        return fmt.Sprintf("Expected at least %d arguments but got %d.", min, got)
+    }
+    return fmt.Sprintf("Expected %d to %d arguments but got %d.", min, max, got)
 }
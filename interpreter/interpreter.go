@@ -5,6 +5,7 @@ import (
 	"io"
 	"reflect"
 	"strconv"
+	"strings"
 
 	. "github.com/elliotthill/golox/language"
 )
@@ -15,6 +16,9 @@ type Interpreter struct {
 	statements  []AbstractStatement
 	environment *Environment
 	globals     *Environment
+	locals      map[AbstractExpression]int //Scope distance of each resolved local
+	frames      []callFrame                //Calls in progress, for error stacks
+	line        int                        //Line of the operation being run, for error values
     stdOut      io.Writer               //We write to a buffer
     stdErr      io.Writer
 }
@@ -25,6 +29,8 @@ func NewInterpreter(stdOut io.Writer, stdErr io.Writer) *Interpreter {
 	//interp.environment = NewEnv(nil)
 	interp.globals = NewEnvironment(nil)
 	interp.environment = interp.globals
+	defineNatives(interp.globals)
+	interp.locals = make(map[AbstractExpression]int)
     interp.stdOut = stdOut
     interp.stdErr = stdErr
 	return interp
@@ -34,13 +40,46 @@ func (interp *Interpreter) SetStatements(statements []AbstractStatement) {
    interp.statements = statements
 }
 
-func (interp *Interpreter) Interpret() {
+// Record scope distances from the resolver, anything it didn't resolve is a global
+func (interp *Interpreter) SetLocals(locals map[AbstractExpression]int) {
+
+	for expr, distance := range locals {
+		interp.locals[expr] = distance
+	}
+}
+
+// Names currently defined in the global scope
+func (interp *Interpreter) Globals() []string {
+
+	names := []string{}
+	for name := range interp.globals.values {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Run the statements, stopping at the first error nothing caught
+func (interp *Interpreter) Interpret() (err *RuntimeError) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch signal := r.(type) {
+			case ThrowSignal:
+				err = newRuntimeError(signal.origin)
+			case string:
+				//Raised outside any call
+				err = newRuntimeError(interp.errorValue(signal))
+			default:
+				panic(r)
+			}
+		}
+	}()
 
 	for _, stmt := range interp.statements {
 
 		interp.execute(stmt)
 	}
-
+	return nil
 }
 
 func (interp *Interpreter) execute(stmt AbstractStatement) {
@@ -79,14 +118,86 @@ func (interp *Interpreter) VisitReturnStatement(stmt Return) interface{} {
 	panic(ReturnValue{value: value})
 }
 
+// Break and continue unwind to their loop in a panic, like return.
+// An empty label means the innermost loop
+type BreakSignal struct {
+	label string
+}
+type ContinueSignal struct {
+	label string
+}
+
+func (interp *Interpreter) VisitBreakStatement(stmt Break) interface{} {
+	panic(BreakSignal{label: labelName(stmt.Label)})
+}
+
+func (interp *Interpreter) VisitContinueStatement(stmt Continue) interface{} {
+	panic(ContinueSignal{label: labelName(stmt.Label)})
+}
+
+func labelName(label *Token) string {
+	if label == nil {
+		return ""
+	}
+	return label.Lexeme
+}
+
+func (interp *Interpreter) VisitDoWhileStatement(stmt DoWhile) interface{} {
+
+	for {
+		if broke := interp.executeLoopBody(stmt.Body, stmt.Label); broke {
+			break
+		}
+
+		if !interp.isTruthy(interp.evaluate(stmt.Condition)) {
+			break
+		}
+	}
+	return nil
+}
+
 func (interp *Interpreter) VisitWhileStatement(stmt While) interface{} {
 
 	for interp.isTruthy(interp.evaluate(stmt.Condition)) {
-		interp.execute(stmt.Body)
+
+		if broke := interp.executeLoopBody(stmt.Body, stmt.Label); broke {
+			break
+		}
+
+		if stmt.Increment != nil {
+			interp.evaluate(stmt.Increment)
+		}
 	}
 	return nil
 }
 
+// Run one iteration, reporting whether it ended in a break. Jumps aimed at
+// another label carry on unwinding to the loop that owns it
+func (interp *Interpreter) executeLoopBody(body AbstractStatement, label string) (broke bool) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch signal := r.(type) {
+			case BreakSignal:
+				if signal.label != "" && signal.label != label {
+					panic(r)
+				}
+				broke = true
+			case ContinueSignal:
+				if signal.label != "" && signal.label != label {
+					panic(r)
+				}
+				broke = false
+			default:
+				panic(r)
+			}
+		}
+	}()
+
+	interp.execute(body)
+	return false
+}
+
 func (interp *Interpreter) VisitBlockStatement(stmt Block) interface{} {
 
 	env := NewEnvironment(interp.environment)
@@ -108,6 +219,141 @@ func (interp *Interpreter) executeBlock(statements []AbstractStatement, env *Env
 	}
 }
 
+func (interp *Interpreter) evaluateIn(expr AbstractExpression, env *Environment) interface{} {
+
+	previous := interp.environment
+	defer func() {
+		interp.environment = previous
+	}()
+
+	interp.environment = env
+	return interp.evaluate(expr)
+}
+
+func (interp *Interpreter) VisitThrowStatement(stmt Throw) interface{} {
+
+	value := interp.evaluate(stmt.Value)
+	interp.line = stmt.Keyword.Line
+	panic(interp.throwSignal(value))
+}
+
+// finally is deferred so it also runs while return, break or throw unwind
+func (interp *Interpreter) VisitTryStatement(stmt Try) interface{} {
+
+	if stmt.Finally != nil {
+		defer interp.executeBlock(stmt.Finally, NewEnvironment(interp.environment))
+	}
+
+	thrown := interp.executeTry(stmt.Body)
+	if thrown == nil {
+		return nil
+	}
+	if stmt.Name == nil {
+		panic(*thrown)
+	}
+
+	env := NewEnvironment(interp.environment)
+	env.Define(stmt.Name.Lexeme, thrown.value)
+	interp.executeBlock(stmt.Catch, env)
+	return nil
+}
+
+// Run a try block, handing back whatever it threw
+func (interp *Interpreter) executeTry(body []AbstractStatement) (thrown *ThrowSignal) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			if message, ok := r.(string); ok {
+				//Raised outside any call made in the block
+				r = interp.throwSignal(interp.errorValue(message))
+			}
+
+			signal, ok := r.(ThrowSignal)
+			if !ok {
+				panic(r)
+			}
+			thrown = &signal
+		}
+	}()
+
+	interp.executeBlock(body, NewEnvironment(interp.environment))
+	return nil
+}
+
+func (interp *Interpreter) VisitMatchStatement(stmt Match) interface{} {
+
+	value := interp.evaluate(stmt.Value)
+
+	for _, arm := range stmt.Arms {
+		for _, pattern := range arm.Patterns {
+
+			//Bindings live in a scope around the arm's body
+			env := NewEnvironment(interp.environment)
+			if !interp.matchPattern(pattern, value, env) {
+				continue
+			}
+			if arm.Guard != nil && !interp.isTruthy(interp.evaluateIn(arm.Guard, env)) {
+				continue
+			}
+
+			interp.executeBlock([]AbstractStatement{arm.Body}, env)
+			return nil
+		}
+	}
+	return nil
+}
+
+// Test value against pattern, defining any bound names in env
+func (interp *Interpreter) matchPattern(pattern AbstractPattern, value interface{}, env *Environment) bool {
+
+	switch pattern := pattern.(type) {
+	case WildcardPattern:
+		return true
+
+	case BindingPattern:
+		env.Define(pattern.Name.Lexeme, value)
+		return true
+
+	case LiteralPattern:
+		return interp.isEqual(pattern.Value, value)
+
+	case ListPattern:
+		list, ok := value.(*RuntimeList)
+		if !ok {
+			return false
+		}
+
+		count := len(pattern.Elements)
+		if len(list.elements) < count || (pattern.Rest == nil && len(list.elements) != count) {
+			return false
+		}
+		for i, element := range pattern.Elements {
+			if !interp.matchPattern(element, list.elements[i], env) {
+				return false
+			}
+		}
+		if pattern.Rest != nil {
+			rest := append([]interface{}{}, list.elements[count:]...)
+			env.Define(pattern.Rest.Lexeme, NewRuntimeList(rest))
+		}
+		return true
+
+	case MapPattern:
+		m, ok := value.(*RuntimeMap)
+		if !ok {
+			return false
+		}
+
+		for i, key := range pattern.Keys {
+			if !m.Has(key) || !interp.matchPattern(pattern.Values[i], m.entries[key], env) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 func (interp *Interpreter) VisitVarStatement(stmt Var) interface{} {
 
 	var value interface{} = nil
@@ -120,6 +366,54 @@ func (interp *Interpreter) VisitVarStatement(stmt Var) interface{} {
 
 }
 
+func (interp *Interpreter) VisitDestructureStatement(stmt Destructure) interface{} {
+
+	value := interp.evaluate(stmt.Initializer)
+	interp.line = stmt.Pattern.Line
+
+	if stmt.Pattern.TokenType == LEFT_BRACE {
+		for _, name := range stmt.Names {
+			interp.environment.Define(name.Lexeme, destructureField(value, name))
+		}
+		return nil
+	}
+
+	list, ok := value.(*RuntimeList)
+	if !ok {
+		panic(fmt.Sprintf("Only lists can be destructured by position, got %s.", stringify(value)))
+	}
+
+	count := len(list.elements)
+	if stmt.Rest == nil && count != len(stmt.Names) {
+		panic(fmt.Sprintf("Expected %d values to unpack, got %d.", len(stmt.Names), count))
+	}
+	if stmt.Rest != nil && count < len(stmt.Names) {
+		panic(fmt.Sprintf("Expected at least %d values to unpack, got %d.", len(stmt.Names), count))
+	}
+
+	for i, name := range stmt.Names {
+		interp.environment.Define(name.Lexeme, list.elements[i])
+	}
+	if stmt.Rest != nil {
+		rest := append([]interface{}{}, list.elements[len(stmt.Names):]...)
+		interp.environment.Define(stmt.Rest.Lexeme, NewRuntimeList(rest))
+	}
+	return nil
+}
+
+// Maps are destructured by key and instances by property
+func destructureField(value interface{}, name Token) interface{} {
+
+	switch object := value.(type) {
+	case *RuntimeMap:
+		return object.Get(name.Lexeme)
+	case *Instance:
+		return object.Get(name)
+	default:
+		panic(fmt.Sprintf("Only maps and instances can be destructured by name, got %s.", stringify(value)))
+	}
+}
+
 func (interp *Interpreter) VisitFunctionStatement(stmt Function) interface{} {
 
 	function := RuntimeFunction{}
@@ -131,6 +425,37 @@ func (interp *Interpreter) VisitFunctionStatement(stmt Function) interface{} {
 	return nil
 }
 
+func (interp *Interpreter) VisitClassStatement(stmt Class) interface{} {
+
+	classEnv := interp.environment
+
+	var superclass *RuntimeClass = nil
+	if stmt.Superclass != nil {
+		value, ok := interp.evaluate(stmt.Superclass).(*RuntimeClass)
+		if !ok {
+			panic("Superclass must be a class.")
+		}
+		superclass = value
+
+		//Methods close over an environment holding super
+		interp.environment = NewEnvironment(classEnv)
+		interp.environment.Define("super", superclass)
+	}
+
+	methods := make(map[string]RuntimeFunction)
+	for _, method := range stmt.Methods {
+		function := RuntimeFunction{declaration: method, closure: interp.environment,
+			isInitializer: method.Name.Lexeme == "init"}
+		methods[method.Name.Lexeme] = function
+	}
+
+	class := &RuntimeClass{name: stmt.Name.Lexeme, superclass: superclass, methods: methods}
+
+	interp.environment = classEnv
+	interp.environment.Define(stmt.Name.Lexeme, class)
+	return nil
+}
+
 func (interp *Interpreter) VisitIfStatement(stmt If) interface{} {
 
 	if interp.isTruthy(interp.evaluate(stmt.Condition)) {
@@ -153,15 +478,32 @@ func (interp *Interpreter) VisitCallExpression(expr Call) interface{} {
 		arguments = append(arguments, interp.evaluate(arg))
 	}
 
+	interp.line = expr.Paren.Line
 	fn, ok := (callee).(callable)
 	if !ok {
 		panic("Can only call functions and classes")
 	}
 
-	if len(arguments) != fn.arity() {
-		panic(fmt.Sprintf("Expected %d arguments but got %d.", fn.arity(), len(arguments)))
+	if len(expr.Named) > 0 {
+		values := []interface{}{}
+		for _, argument := range expr.Named {
+			values = append(values, interp.evaluate(argument.Value))
+		}
+		arguments = bindNamed(fn, arguments, expr.Named, values)
+	}
+
+	min, max := fn.arity()
+	if len(arguments) < min || (max >= 0 && len(arguments) > max) {
+		panic(arityError(min, max, len(arguments)))
+	}
+
+	//Errors from natives are reported from the script that called them
+	if _, native := fn.(NativeFunction); native {
+		return fn.call(interp, arguments)
 	}
 
+	interp.frames = append(interp.frames, callFrame{callee: stringify(fn), line: expr.Paren.Line})
+	defer interp.leaveCall()
 	return fn.call(interp, arguments)
 }
 
@@ -171,7 +513,7 @@ func (interp *Interpreter) VisitFunctionExpression(expr FunctionExpression) inte
 	function := RuntimeFunction{}
 
     //We replace the expression with the function statement here
-    functionStmt := Function{Params: expr.Params, Body: expr.Body}
+    functionStmt := Function{Params: expr.Params, Defaults: expr.Defaults, Rest: expr.Rest, Body: expr.Body}
 	function.declaration = functionStmt;
 
     function.closure = interp.environment
@@ -182,6 +524,115 @@ func (interp *Interpreter) VisitFunctionExpression(expr FunctionExpression) inte
 }
 
 
+func (interp *Interpreter) VisitGetExpression(expr Get) interface{} {
+
+	object := interp.evaluate(expr.Object)
+	interp.line = expr.Name.Line
+
+	if instance, ok := object.(*Instance); ok {
+		return instance.Get(expr.Name)
+	}
+
+	panic("Only instances have properties.")
+}
+
+func (interp *Interpreter) VisitSetExpression(expr Set) interface{} {
+
+	object := interp.evaluate(expr.Object)
+	interp.line = expr.Name.Line
+
+	instance, ok := object.(*Instance)
+	if !ok {
+		panic("Only instances have fields.")
+	}
+
+	value := interp.evaluate(expr.Value)
+	instance.Set(expr.Name, value)
+	return value
+}
+
+func (interp *Interpreter) VisitThisExpression(expr *This) interface{} {
+	return interp.lookupVariable(expr.Keyword.Lexeme, expr)
+}
+
+func (interp *Interpreter) VisitSuperExpression(expr *Super) interface{} {
+
+	//this lives in the scope just inside super
+	distance := interp.locals[expr]
+	superclass := interp.environment.GetAt(distance, "super").(*RuntimeClass)
+	instance := interp.environment.GetAt(distance-1, "this").(*Instance)
+
+	method, ok := superclass.findMethod(expr.Method.Lexeme)
+	if !ok {
+		panic(fmt.Sprintf("Undefined property '%s'.", expr.Method.Lexeme))
+	}
+	return method.bind(instance)
+}
+
+func (interp *Interpreter) VisitListExpression(expr List) interface{} {
+
+	elements := []interface{}{}
+	for _, element := range expr.Elements {
+		elements = append(elements, interp.evaluate(element))
+	}
+	return NewRuntimeList(elements)
+}
+
+func (interp *Interpreter) VisitMapExpression(expr Map) interface{} {
+
+	m := NewRuntimeMap()
+	for i := range expr.Keys {
+		key := interp.evaluate(expr.Keys[i])
+		m.Set(key, interp.evaluate(expr.Values[i]))
+	}
+	return m
+}
+
+// Lists and maps support object[index]
+type indexable interface {
+	Get(index interface{}) interface{}
+	Set(index interface{}, value interface{})
+}
+
+func (interp *Interpreter) VisitIndexExpression(expr Index) interface{} {
+
+	object := interp.evaluate(expr.Object)
+	index := interp.evaluate(expr.Index)
+	interp.line = expr.Bracket.Line
+
+	container, ok := object.(indexable)
+	if !ok {
+		panic("Only lists and maps can be indexed.")
+	}
+	return container.Get(index)
+}
+
+func (interp *Interpreter) VisitIndexSetExpression(expr IndexSet) interface{} {
+
+	object := interp.evaluate(expr.Object)
+	index := interp.evaluate(expr.Index)
+
+	container, ok := object.(indexable)
+	if !ok {
+		panic("Only lists and maps can be indexed.")
+	}
+
+	value := interp.evaluate(expr.Value)
+	interp.line = expr.Bracket.Line
+	container.Set(index, value)
+	return value
+}
+
+func (interp *Interpreter) VisitInterpolationExpression(expr Interpolation) interface{} {
+
+	var str strings.Builder
+
+	for _, part := range expr.Parts {
+		str.WriteString(interp.stringify(interp.evaluate(part)))
+	}
+	return str.String()
+}
+
 func (interp *Interpreter) evaluate(expr AbstractExpression) interface{} {
 	return expr.Accept(interp)
 }
@@ -192,6 +643,7 @@ func (interp *Interpreter) VisitLiteralExpression(expr Literal) interface{} {
 
 func (interp *Interpreter) VisitUnaryExpression(expr Unary) interface{} {
 	right := interp.evaluate(expr.Right)
+	interp.line = expr.Operator.Line
 
 	switch expr.Operator.TokenType {
 	case BANG:
@@ -212,6 +664,7 @@ func (interp *Interpreter) VisitBinaryExpression(expr Binary) interface{} {
 
 	left := interp.evaluate(expr.Left)
 	right := interp.evaluate(expr.Right)
+	interp.line = expr.Operator.Line
 
 	left_double, _ := left.(float64)
 	right_double, _ := right.(float64)
@@ -245,33 +698,91 @@ func (interp *Interpreter) VisitBinaryExpression(expr Binary) interface{} {
 	return nil
 }
 
-func (interp *Interpreter) VisitVariableExpression(expr Variable) interface{} {
+func (interp *Interpreter) VisitTernaryExpression(expr Ternary) interface{} {
+
+	//Only the chosen branch is evaluated
+	if interp.isTruthy(interp.evaluate(expr.Left)) {
+		return interp.evaluate(expr.Middle)
+	}
+	return interp.evaluate(expr.Right)
+}
+
+func (interp *Interpreter) VisitLogicalExpression(expr Logical) interface{} {
 
-	val := interp.lookupVariable(expr.Name.Lexeme)
+	left := interp.evaluate(expr.Left)
 
-	if val == nil {
-		panic("Undefined variable " + expr.Name.Lexeme + " ")
+	//Short circuit, returning the operand that decided the result
+	if expr.Operator.TokenType == OR {
+		if interp.isTruthy(left) {
+			return left
+		}
+	} else if !interp.isTruthy(left) {
+		return left
 	}
-	return val
+
+	return interp.evaluate(expr.Right)
 }
 
-func (interp *Interpreter) VisitAssignExpression(expr Assign) interface{} {
+func (interp *Interpreter) VisitVariableExpression(expr *Variable) interface{} {
+	interp.line = expr.Name.Line
+	return interp.lookupVariable(expr.Name.Lexeme, expr)
+}
 
-	value := interp.evaluate(expr.Value)
-	//interp.env[expr.name.lexeme] = value
-	interp.environment.Assign(expr.Name.Lexeme, value)
+func (interp *Interpreter) VisitAssignExpression(expr *Assign) interface{} {
 
+	value := interp.evaluate(expr.Value)
+	interp.line = expr.Name.Line
+	interp.assignVariable(expr.Name.Lexeme, expr, value)
 	return value
 }
 
-func (interp *Interpreter) lookupVariable(name string) interface{} {
+func (interp *Interpreter) VisitParallelAssignExpression(expr ParallelAssign) interface{} {
 
-	value := interp.environment.Get(name)
+	values := []interface{}{}
+	for _, value := range expr.Values {
+		values = append(values, interp.evaluate(value))
+	}
 
-	if value == nil {
-		value = interp.globals.Get(name)
+	for i, target := range expr.Targets {
+		switch target := target.(type) {
+		case *Variable:
+			interp.assignVariable(target.Name.Lexeme, target, values[i])
+		case Get:
+			instance, ok := interp.evaluate(target.Object).(*Instance)
+			if !ok {
+				panic("Only instances have fields.")
+			}
+			instance.Set(target.Name, values[i])
+		case Index:
+			container, ok := interp.evaluate(target.Object).(indexable)
+			if !ok {
+				panic("Only lists and maps can be indexed.")
+			}
+			container.Set(interp.evaluate(target.Index), values[i])
+		}
 	}
+	return nil
+}
 
+func (interp *Interpreter) assignVariable(name string, expr AbstractExpression, value interface{}) {
+
+	if distance, ok := interp.locals[expr]; ok {
+		interp.environment.AssignAt(distance, name, value)
+	} else {
+		interp.globals.Assign(name, value)
+	}
+}
+
+func (interp *Interpreter) lookupVariable(name string, expr AbstractExpression) interface{} {
+
+	if distance, ok := interp.locals[expr]; ok {
+		return interp.environment.GetAt(distance, name)
+	}
+
+	value, ok := interp.globals.Get(name)
+	if !ok {
+		panic("Undefined variable '" + name + "'.")
+	}
 	return value
 }
 
@@ -285,6 +796,19 @@ func (interp *Interpreter) isEqual(a interface{}, b interface{}) bool {
 		return false
 	}
 
+	//Objects are equal only to themselves
+	if instance, ok := a.(*Instance); ok {
+		return instance == b
+	}
+	if class, ok := a.(*RuntimeClass); ok {
+		return class == b
+	}
+	//Each native is defined once, so its name identifies it
+	if native, ok := a.(NativeFunction); ok {
+		other, ok := b.(NativeFunction)
+		return ok && native.name == other.name
+	}
+
 	return reflect.DeepEqual(a, b)
 }
 
@@ -307,6 +831,8 @@ func (interp *Interpreter) isTruthy(obj interface{}) bool {
 func (interp *Interpreter) tryGetNumber(operand interface{}) float64 {
 
 	switch v := operand.(type) {
+	case float64:
+		return v
 	case string:
 		try_float, err := strconv.ParseFloat(v, 64)
 		if err != nil {
@@ -320,23 +846,34 @@ func (interp *Interpreter) tryGetNumber(operand interface{}) float64 {
 }
 
 func (interp *Interpreter) stringify(thing interface{}) string {
+	return stringify(thing)
+}
+
+func stringify(thing interface{}) string {
+	return stringifyNested(thing, map[interface{}]bool{})
+}
+
+// printing holds the containers already being printed further out, one that
+// contains itself prints as a placeholder instead of recursing forever
+func stringifyNested(thing interface{}, printing map[interface{}]bool) string {
 
-	if thing == nil {
+	switch value := thing.(type) {
+	case nil:
 		return "nil"
+	case *RuntimeList:
+		if printing[value] {
+			return "[...]"
+		}
+		printing[value] = true
+		defer delete(printing, value)
+		return value.format(printing)
+	case *RuntimeMap:
+		if printing[value] {
+			return "{...}"
+		}
+		printing[value] = true
+		defer delete(printing, value)
+		return value.format(printing)
 	}
 	return fmt.Sprint(thing)
-
-	switch v := thing.(type) {
-	case bool:
-		return strconv.FormatBool(v)
-	case int:
-		return fmt.Sprintf("Integer: %v", v)
-	case float64:
-		return fmt.Sprintf("Float64: %v", v)
-	case string:
-		return v
-	default:
-		return "<glox object>"
-	}
-
 }
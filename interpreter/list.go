@@ -0,0 +1,59 @@
+package interpreter
+
+import (
+    "fmt"
+    "math"
+    "strings"
+)
+
+//Lists are shared by reference, so push on one name is seen through another
+type RuntimeList struct{
+    elements []interface{}
+}
+
+func NewRuntimeList(elements []interface{}) *RuntimeList {
+    list := new(RuntimeList)
+    list.elements = elements
+    return list
+}
+
+func (list *RuntimeList) Get(index interface{}) interface{} {
+    return list.elements[list.position(index, len(list.elements)-1)]
+}
+
+func (list *RuntimeList) Set(index interface{}, value interface{}) {
+    list.elements[list.position(index, len(list.elements)-1)] = value
+}
+
+//Convert a golox index into a slice position no greater than max,
+//negative indexes count back from the end
+func (list *RuntimeList) position(index interface{}, max int) int {
+
+    number, ok := index.(float64)
+    if !ok || number != math.Trunc(number) {
+        panic(fmt.Sprintf("List index must be an integer, got %s.", stringify(index)))
+    }
+
+    position := int(number)
+    if position < 0 {
+        position += len(list.elements)
+    }
+
+    if position < 0 || position > max {
+        panic(fmt.Sprintf("Index %s out of range for list of length %d.", stringify(index), len(list.elements)))
+    }
+    return position
+}
+
+func (list *RuntimeList) String() string {
+    return stringify(list)
+}
+
+func (list *RuntimeList) format(printing map[interface{}]bool) string {
+
+    elements := []string{}
+    for _, element := range list.elements {
+        elements = append(elements, stringifyNested(element, printing))
+    }
+    return "[" + strings.Join(elements, ", ") + "]"
+}
@@ -0,0 +1,97 @@
+package interpreter
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+//Maps are keyed by strings and numbers and shared by reference like lists
+type RuntimeMap struct{
+    entries map[interface{}]interface{}
+}
+
+func NewRuntimeMap() *RuntimeMap {
+    m := new(RuntimeMap)
+    m.entries = make(map[interface{}]interface{})
+    return m
+}
+
+func (m *RuntimeMap) Get(key interface{}) interface{} {
+
+    value, ok := m.entries[checkKey(key)]
+    if !ok {
+        panic(fmt.Sprintf("Key %s not found in map.", stringify(key)))
+    }
+    return value
+}
+
+func (m *RuntimeMap) Set(key interface{}, value interface{}) {
+    m.entries[checkKey(key)] = value
+}
+
+func (m *RuntimeMap) Has(key interface{}) bool {
+    _, ok := m.entries[checkKey(key)]
+    return ok
+}
+
+//Remove key, reporting whether it was present
+func (m *RuntimeMap) Delete(key interface{}) bool {
+
+    ok := m.Has(key)
+    delete(m.entries, key)
+    return ok
+}
+
+//Keys in a stable order, numbers ascending then strings ascending
+func (m *RuntimeMap) Keys() []interface{} {
+
+    keys := []interface{}{}
+    for key := range m.entries {
+        keys = append(keys, key)
+    }
+
+    sort.Slice(keys, func(i, j int) bool {
+        a, aNumber := keys[i].(float64)
+        b, bNumber := keys[j].(float64)
+
+        if aNumber && bNumber {
+            return a < b
+        }
+        if aNumber != bNumber {
+            return aNumber
+        }
+        return keys[i].(string) < keys[j].(string)
+    })
+    return keys
+}
+
+func (m *RuntimeMap) String() string {
+    return stringify(m)
+}
+
+func (m *RuntimeMap) format(printing map[interface{}]bool) string {
+
+    entries := []string{}
+    for _, key := range m.Keys() {
+        entries = append(entries, stringify(key)+": "+stringifyNested(m.entries[key], printing))
+    }
+    return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func checkKey(key interface{}) interface{} {
+
+    switch k := key.(type) {
+    case string:
+        return key
+    case float64:
+        //NaN never equals itself, so its entry could never be found again
+        if math.IsNaN(k) {
+            panic("Map key can't be NaN.")
+        }
+        return key
+    default:
+        panic(fmt.Sprintf("Map key must be a string or number, got %s.", stringify(key)))
+    }
+}
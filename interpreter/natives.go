@@ -0,0 +1,160 @@
+package interpreter
+
+import (
+    "fmt"
+    "unicode/utf8"
+)
+
+//Built-in function implemented in Go
+type NativeFunction struct{
+    callable
+    name string
+    parameters int
+    function func(interp *Interpreter, arguments []interface{}) interface{}
+}
+
+func (f NativeFunction) call(interp *Interpreter, arguments []interface{}) interface{} {
+    return f.function(interp, arguments)
+}
+
+func (f NativeFunction) arity() (int, int) {
+    return f.parameters, f.parameters
+}
+
+func (f NativeFunction) String() string {
+    return "<native fn " + f.name + ">"
+}
+
+func defineNatives(env *Environment) {
+
+    natives := []NativeFunction{
+        {name: "len", parameters: 1, function: nativeLen},
+        {name: "push", parameters: 2, function: nativePush},
+        {name: "pop", parameters: 1, function: nativePop},
+        {name: "insert", parameters: 3, function: nativeInsert},
+        {name: "slice", parameters: 3, function: nativeSlice},
+        {name: "has", parameters: 2, function: nativeHas},
+        {name: "delete", parameters: 2, function: nativeDelete},
+        {name: "keys", parameters: 1, function: nativeKeys},
+        {name: "values", parameters: 1, function: nativeValues},
+        {name: "Error", parameters: 1, function: nativeError},
+    }
+
+    for _, native := range natives {
+        env.Define(native.name, native)
+    }
+}
+
+func listArgument(name string, argument interface{}) *RuntimeList {
+
+    list, ok := argument.(*RuntimeList)
+    if !ok {
+        panic(fmt.Sprintf("%s() expects a list, got %s.", name, stringify(argument)))
+    }
+    return list
+}
+
+func mapArgument(name string, argument interface{}) *RuntimeMap {
+
+    m, ok := argument.(*RuntimeMap)
+    if !ok {
+        panic(fmt.Sprintf("%s() expects a map, got %s.", name, stringify(argument)))
+    }
+    return m
+}
+
+//len(value) counts list elements, map entries or string characters
+func nativeLen(interp *Interpreter, arguments []interface{}) interface{} {
+
+    switch v := arguments[0].(type) {
+    case *RuntimeList:
+        return float64(len(v.elements))
+    case *RuntimeMap:
+        return float64(len(v.entries))
+    case string:
+        return float64(utf8.RuneCountInString(v))
+    default:
+        panic(fmt.Sprintf("len() expects a list, map or string, got %s.", stringify(v)))
+    }
+}
+
+//push(list, value) appends and returns the new length
+func nativePush(interp *Interpreter, arguments []interface{}) interface{} {
+
+    list := listArgument("push", arguments[0])
+    list.elements = append(list.elements, arguments[1])
+    return float64(len(list.elements))
+}
+
+//pop(list) removes and returns the last element
+func nativePop(interp *Interpreter, arguments []interface{}) interface{} {
+
+    list := listArgument("pop", arguments[0])
+    if len(list.elements) == 0 {
+        panic("pop() from empty list.")
+    }
+
+    last := list.elements[len(list.elements)-1]
+    list.elements = list.elements[:len(list.elements)-1]
+    return last
+}
+
+//insert(list, index, value) places value before index, index may be the length to append
+func nativeInsert(interp *Interpreter, arguments []interface{}) interface{} {
+
+    list := listArgument("insert", arguments[0])
+    position := list.position(arguments[1], len(list.elements))
+
+    list.elements = append(list.elements, nil)
+    copy(list.elements[position+1:], list.elements[position:])
+    list.elements[position] = arguments[2]
+    return nil
+}
+
+//slice(list, start, end) copies the elements from start up to but not including end
+func nativeSlice(interp *Interpreter, arguments []interface{}) interface{} {
+
+    list := listArgument("slice", arguments[0])
+    start := list.position(arguments[1], len(list.elements))
+    end := list.position(arguments[2], len(list.elements))
+
+    if start > end {
+        panic(fmt.Sprintf("slice() start %d is after end %d.", start, end))
+    }
+
+    elements := make([]interface{}, end-start)
+    copy(elements, list.elements[start:end])
+    return NewRuntimeList(elements)
+}
+
+//has(map, key) reports whether key is present
+func nativeHas(interp *Interpreter, arguments []interface{}) interface{} {
+    return mapArgument("has", arguments[0]).Has(arguments[1])
+}
+
+//delete(map, key) removes key, returning whether it was present
+func nativeDelete(interp *Interpreter, arguments []interface{}) interface{} {
+    return mapArgument("delete", arguments[0]).Delete(arguments[1])
+}
+
+//keys(map) lists the keys in sorted order
+func nativeKeys(interp *Interpreter, arguments []interface{}) interface{} {
+    return NewRuntimeList(mapArgument("keys", arguments[0]).Keys())
+}
+
+//values(map) lists the values in the same order as keys(map)
+func nativeValues(interp *Interpreter, arguments []interface{}) interface{} {
+
+    m := mapArgument("values", arguments[0])
+    values := []interface{}{}
+    for _, key := range m.Keys() {
+        values = append(values, m.entries[key])
+    }
+    return NewRuntimeList(values)
+}
+
+//Error(message) makes an error value like those runtime errors produce
+func nativeError(interp *Interpreter, arguments []interface{}) interface{} {
+
+    return interp.errorValue(stringify(arguments[0]))
+}
@@ -24,17 +24,30 @@ func (literal Literal) Accept(visitor ExpressionVisitor) interface{} {
 }
 
 
-//Assign
+//Assign, Variable, This and Super are used as pointers so the resolver can
+//record a scope distance for each use
 type Assign struct{
     AbstractExpression
     Name Token
     Value AbstractExpression
 }
 
-func (assign Assign) Accept(visitor ExpressionVisitor) interface{} {
+func (assign *Assign) Accept(visitor ExpressionVisitor) interface{} {
     return visitor.VisitAssignExpression(assign)
 }
 
+//Parallel assignment a, b = b, a, all values are evaluated before any target is assigned
+type ParallelAssign struct{
+    AbstractExpression
+    Equals Token
+    Targets []AbstractExpression
+    Values []AbstractExpression
+}
+
+func (parallelAssign ParallelAssign) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitParallelAssignExpression(parallelAssign)
+}
+
 //Unary
 type Unary struct{
     AbstractExpression
@@ -88,7 +101,7 @@ type Variable struct{
     Name Token
 }
 
-func (variable Variable) Accept(visitor ExpressionVisitor) interface{} {
+func (variable *Variable) Accept(visitor ExpressionVisitor) interface{} {
     return visitor.VisitVariableExpression(variable)
 }
 
@@ -109,6 +122,13 @@ type Call struct{
     Callee AbstractExpression
     Paren Token
     Arguments []AbstractExpression
+    Named []NamedArgument //Always follow the positional arguments
+}
+
+//Named argument, name: value
+type NamedArgument struct{
+    Name Token
+    Value AbstractExpression
 }
 
 func (call Call) Accept(visitor ExpressionVisitor) interface{} {
@@ -120,6 +140,8 @@ func (call Call) Accept(visitor ExpressionVisitor) interface{} {
 type FunctionExpression struct{
     AbstractExpression
     Params []Token
+    Defaults []AbstractExpression
+    Rest *Token
     Body []AbstractStatement
 }
 
@@ -127,17 +149,129 @@ func (funcExpr FunctionExpression) Accept(visitor ExpressionVisitor) interface{}
     return visitor.VisitFunctionExpression(funcExpr);
 }
 
+//Get property, object.name
+type Get struct{
+    AbstractExpression
+    Object AbstractExpression
+    Name Token
+}
+
+func (get Get) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitGetExpression(get)
+}
+
+//Set property, object.name = value
+type Set struct{
+    AbstractExpression
+    Object AbstractExpression
+    Name Token
+    Value AbstractExpression
+}
+
+func (set Set) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitSetExpression(set)
+}
+
+//This
+type This struct{
+    AbstractExpression
+    Keyword Token
+}
+
+func (this *This) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitThisExpression(this)
+}
+
+//Super, super.method
+type Super struct{
+    AbstractExpression
+    Keyword Token
+    Method Token
+}
+
+func (super *Super) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitSuperExpression(super)
+}
+
+//List literal [a, b, c]
+type List struct{
+    AbstractExpression
+    Bracket Token
+    Elements []AbstractExpression
+}
+
+func (list List) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitListExpression(list)
+}
+
+//Map literal {key: value, ...}
+type Map struct{
+    AbstractExpression
+    Brace Token
+    Keys []AbstractExpression
+    Values []AbstractExpression
+}
+
+func (_map Map) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitMapExpression(_map)
+}
+
+//Index, object[index]
+type Index struct{
+    AbstractExpression
+    Object AbstractExpression
+    Bracket Token
+    Index AbstractExpression
+}
+
+func (index Index) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitIndexExpression(index)
+}
+
+//IndexSet, object[index] = value
+type IndexSet struct{
+    AbstractExpression
+    Object AbstractExpression
+    Bracket Token
+    Index AbstractExpression
+    Value AbstractExpression
+}
+
+func (indexSet IndexSet) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitIndexSetExpression(indexSet)
+}
+
+//Interpolation "a ${b} c", parts alternate between string literals and expressions
+type Interpolation struct{
+    AbstractExpression
+    Parts []AbstractExpression
+}
+
+func (interpolation Interpolation) Accept(visitor ExpressionVisitor) interface{} {
+    return visitor.VisitInterpolationExpression(interpolation)
+}
+
 type ExpressionVisitor interface {
-    VisitAssignExpression(expression Assign) interface{}
+    VisitAssignExpression(expression *Assign) interface{}
+    VisitParallelAssignExpression(expression ParallelAssign) interface{}
     VisitBinaryExpression(expression Binary) interface{}
     VisitGroupingExpression(expression Grouping) interface{}
     VisitLiteralExpression(expression Literal) interface{}
     VisitLogicalExpression(expression Logical) interface{}
     VisitTernaryExpression(expression Ternary) interface{}
     VisitUnaryExpression(expression Unary) interface{}
-    VisitVariableExpression(expression Variable) interface{}
+    VisitVariableExpression(expression *Variable) interface{}
     VisitCallExpression(expression Call) interface{}
     VisitFunctionExpression(expression FunctionExpression) interface{}
+    VisitInterpolationExpression(expression Interpolation) interface{}
+    VisitGetExpression(expression Get) interface{}
+    VisitSetExpression(expression Set) interface{}
+    VisitThisExpression(expression *This) interface{}
+    VisitSuperExpression(expression *Super) interface{}
+    VisitListExpression(expression List) interface{}
+    VisitMapExpression(expression Map) interface{}
+    VisitIndexExpression(expression Index) interface{}
+    VisitIndexSetExpression(expression IndexSet) interface{}
 }
 
 
@@ -189,6 +323,64 @@ func (_var Var) Accept(visitor StatementVisitor) interface{} {
     return visitor.VisitVarStatement(_var)
 }
 
+//Destructuring var, Pattern is the opening ( or [ for positions and { for names
+type Destructure struct{
+    AbstractStatement
+    Pattern Token
+    Names []Token
+    Rest *Token
+    Initializer AbstractExpression
+}
+
+func (destructure Destructure) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitDestructureStatement(destructure)
+}
+
+//Match, the first arm with a matching pattern and a truthy guard runs
+type Match struct{
+    AbstractStatement
+    Keyword Token
+    Value AbstractExpression
+    Arms []MatchArm
+}
+
+//case pattern, pattern if guard => body
+type MatchArm struct{
+    Case Token
+    Patterns []AbstractPattern
+    Guard AbstractExpression
+    Body AbstractStatement
+}
+
+func (match Match) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitMatchStatement(match)
+}
+
+//Throw
+type Throw struct{
+    AbstractStatement
+    Keyword Token
+    Value AbstractExpression
+}
+
+func (throw Throw) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitThrowStatement(throw)
+}
+
+//Try, Name and Catch are nil without a catch clause and Finally without a finally clause
+type Try struct{
+    AbstractStatement
+    Keyword Token
+    Body []AbstractStatement
+    Name *Token
+    Catch []AbstractStatement
+    Finally []AbstractStatement
+}
+
+func (try Try) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitTryStatement(try)
+}
+
 //If
 type If struct{
     AbstractStatement
@@ -206,12 +398,48 @@ type While struct{
     AbstractStatement
     Condition AbstractExpression
     Body AbstractStatement
+    Increment AbstractExpression    //Desugared for loops run this after the body, even on continue
+    Label string                    //Empty unless the loop is labelled
 }
 
 func (while While) Accept(visitor StatementVisitor) interface{} {
     return visitor.VisitWhileStatement(while)
 }
 
+//DoWhile
+type DoWhile struct{
+    AbstractStatement
+    Body AbstractStatement
+    Condition AbstractExpression
+    Label string
+}
+
+func (doWhile DoWhile) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitDoWhileStatement(doWhile)
+}
+
+//Break
+type Break struct{
+    AbstractStatement
+    Keyword Token
+    Label *Token    //nil breaks the innermost loop
+}
+
+func (_break Break) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitBreakStatement(_break)
+}
+
+//Continue
+type Continue struct{
+    AbstractStatement
+    Keyword Token
+    Label *Token
+}
+
+func (_continue Continue) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitContinueStatement(_continue)
+}
+
 //Return
 type Return struct{
     AbstractStatement
@@ -224,11 +452,13 @@ func (_return Return) Accept(visitor StatementVisitor) interface{} {
 }
 
 
-//Function
+//Function, Defaults holds nil for parameters without a default value
 type Function struct{
     AbstractStatement
     Name Token
     Params []Token
+    Defaults []AbstractExpression
+    Rest *Token
     Body []AbstractStatement
 }
 
@@ -236,14 +466,33 @@ func (_function Function) Accept(visitor StatementVisitor) interface{} {
     return visitor.VisitFunctionStatement(_function)
 }
 
+//Class
+type Class struct{
+    AbstractStatement
+    Name Token
+    Superclass *Variable    //nil unless declared with <
+    Methods []Function
+}
+
+func (class Class) Accept(visitor StatementVisitor) interface{} {
+    return visitor.VisitClassStatement(class)
+}
+
 type StatementVisitor interface{
     VisitBlockStatement(statement Block) interface{}
-    //visitClassStatement(statement Class)
+    VisitClassStatement(statement Class) interface{}
     VisitExpressionStatement(statement Expression) interface{}
     VisitPrintStatement(statement Print) interface{}
     VisitVarStatement(statement Var) interface{}
+    VisitDestructureStatement(statement Destructure) interface{}
     VisitIfStatement(statement If) interface{}
+    VisitMatchStatement(statement Match) interface{}
+    VisitThrowStatement(statement Throw) interface{}
+    VisitTryStatement(statement Try) interface{}
     VisitWhileStatement(statement While) interface{}
+    VisitDoWhileStatement(statement DoWhile) interface{}
+    VisitBreakStatement(statement Break) interface{}
+    VisitContinueStatement(statement Continue) interface{}
     VisitReturnStatement(statement Return) interface{}
     VisitFunctionStatement(statement Function) interface{}
 }
@@ -0,0 +1,42 @@
+package language
+
+//Patterns tested against a value by match, they are inspected by type rather than visited
+type AbstractPattern interface{
+    pattern()
+}
+
+//_ matches anything without binding it
+type WildcardPattern struct{
+    Underscore Token
+}
+
+//A name matches anything and binds the value to it
+type BindingPattern struct{
+    Name Token
+}
+
+//Numbers, strings, true, false and nil match equal values
+type LiteralPattern struct{
+    Token Token
+    Value interface{}
+}
+
+//[a, b, ...rest] matches lists of the same length, or at least as long with a rest name
+type ListPattern struct{
+    Bracket Token
+    Elements []AbstractPattern
+    Rest *Token
+}
+
+//{key: pattern} matches maps with every key present, other keys are ignored
+type MapPattern struct{
+    Brace Token
+    Keys []interface{}
+    Values []AbstractPattern
+}
+
+func (WildcardPattern) pattern() {}
+func (BindingPattern) pattern() {}
+func (LiteralPattern) pattern() {}
+func (ListPattern) pattern() {}
+func (MapPattern) pattern() {}
@@ -10,14 +10,20 @@ const (
     RIGHT_PAREN TokenType = "RIGHT_PAREN"
     LEFT_BRACE TokenType = "LEFT_BRACE"
     RIGHT_BRACE TokenType = "RIGHT_BRACE"
+    LEFT_BRACKET TokenType = "LEFT_BRACKET"
+    RIGHT_BRACKET TokenType = "RIGHT_BRACKET"
 
     COMMA TokenType = "COMMA"
     DOT TokenType = "DOT"
+    ELLIPSIS TokenType = "ELLIPSIS"
     MINUS TokenType = "MINUS"
     PLUS TokenType = "PLUS"
     SEMICOLON TokenType = "SEMICOLON"
     SLASH TokenType = "SLASH"
     STAR TokenType = "STAR"
+    QUESTION TokenType = "QUESTION"
+    COLON TokenType = "COLON"
+    ARROW TokenType = "ARROW"
 
     //One or two character tokens
     BANG TokenType = "BANG"
@@ -32,6 +38,9 @@ const (
     //Literals
     IDENTIFIER TokenType = "IDENTIFIER"
     STRING TokenType = "STRING"
+    INTERPOLATION TokenType = "INTERPOLATION"   //String part preceding a ${expr}
+    INTERPOLATION_MIDDLE TokenType = "INTERPOLATION_MIDDLE"   //String part between two ${expr}
+    INTERPOLATION_END TokenType = "INTERPOLATION_END"   //String part after the last ${expr}
     NUMBER TokenType = "NUMBER"
 
     //Keywords
@@ -52,6 +61,14 @@ const (
     VAR TokenType = "VAR"
     WHILE TokenType = "WHILE"
     BREAK TokenType = "BREAK"
+    CONTINUE TokenType = "CONTINUE"
+    DO TokenType = "DO"
+    MATCH TokenType = "MATCH"
+    CASE TokenType = "CASE"
+    THROW TokenType = "THROW"
+    TRY TokenType = "TRY"
+    CATCH TokenType = "CATCH"
+    FINALLY TokenType = "FINALLY"
 
     EOF TokenType = "EOF"
 
@@ -75,25 +92,41 @@ var Keywords = map[string]TokenType{
     "var": "VAR",
     "while": "WHILE",
     "break": "BREAK",
+    "continue": "CONTINUE",
+    "do": "DO",
+    "match": "MATCH",
+    "case": "CASE",
+    "throw": "THROW",
+    "try": "TRY",
+    "catch": "CATCH",
+    "finally": "FINALLY",
 }
 
 type Token struct{
     TokenType TokenType;
     Lexeme string;
     Literal interface{};
-    Line int;
+    Line int;       //1-based line the token starts on
+    Column int;     //1-based column the token starts on
+    Start int;      //Byte offset of the first character
+    End int;        //Byte offset one past the last character
+    LeadingTrivia []Trivia;     //Comments and whitespace before the token
+    TrailingTrivia []Trivia;    //Comments and whitespace after the token on the same line
 }
 
-func NewToken(tokenType TokenType, lexeme string, literal interface{}, line int) *Token {
+func NewToken(tokenType TokenType, lexeme string, literal interface{}, line int, column int, start int, end int) *Token {
     token := new(Token)
     token.TokenType = tokenType
     token.Lexeme = lexeme
     token.Literal = literal
     token.Line = line
+    token.Column = column
+    token.Start = start
+    token.End = end
     return token
 }
 
 func (token *Token) String() string {
 
-    return fmt.Sprintf("%s %s %s",token.TokenType,token.Lexeme, token.Literal)
+    return fmt.Sprintf("%d:%d %s %s %v", token.Line, token.Column, token.TokenType, token.Lexeme, token.Literal)
 }
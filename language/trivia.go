@@ -0,0 +1,21 @@
+package language
+
+type TriviaKind string
+
+const (
+    WHITESPACE TriviaKind = "WHITESPACE"
+    NEWLINE TriviaKind = "NEWLINE"
+    LINE_COMMENT TriviaKind = "LINE_COMMENT"
+    DOC_COMMENT TriviaKind = "DOC_COMMENT"         // /// comments
+    BLOCK_COMMENT TriviaKind = "BLOCK_COMMENT"
+)
+
+//Source text that isn't a token, only kept when the scanner is asked to
+type Trivia struct{
+    Kind TriviaKind;
+    Text string;
+    Line int;
+    Column int;
+    Start int;
+    End int;
+}
@@ -0,0 +1,35 @@
+package lexer
+
+import "fmt"
+
+type DiagnosticKind string
+
+const (
+    UNEXPECTED_CHARACTER DiagnosticKind = "UNEXPECTED_CHARACTER"
+    UNTERMINATED_STRING DiagnosticKind = "UNTERMINATED_STRING"
+    INVALID_NUMBER DiagnosticKind = "INVALID_NUMBER"
+    INVALID_ESCAPE DiagnosticKind = "INVALID_ESCAPE"
+    UNTERMINATED_COMMENT DiagnosticKind = "UNTERMINATED_COMMENT"
+    INVALID_UTF8 DiagnosticKind = "INVALID_UTF8"
+    READ_ERROR DiagnosticKind = "READ_ERROR"
+)
+
+//A problem found while scanning, the offending lexeme is kept so callers can
+//render it however they like
+type Diagnostic struct {
+    Kind DiagnosticKind;
+    Message string;
+    Line int;
+    Column int;
+    Lexeme string;
+}
+
+func (diagnostic Diagnostic) String() string {
+
+    return fmt.Sprintf("[line %d:%d] %s: %s '%s'", diagnostic.Line, diagnostic.Column,
+        diagnostic.Kind, diagnostic.Message, diagnostic.Lexeme)
+}
+
+func (diagnostic Diagnostic) Error() string {
+    return diagnostic.String()
+}
@@ -1,18 +1,38 @@
 package lexer
 
 import (
-	"fmt"
+	"io"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
     . "github.com/elliotthill/golox/language"
 )
 
 type Scanner struct {
-    source string;
+    source string;      //Buffered source, the whole program unless reading from a reader
+    reader io.Reader;   //Remaining input, nil once exhausted
+    offset int;         //Byte offset of source[0] in the whole input
     current int;
     start int;
     line int;
-    tokens []Token
+    lineColumn int;     //Runes consumed on the current line
+    startLine int;
+    startColumn int;
+    pending []Token     //Scanned but not yet returned by NextToken
+    preserveTrivia bool
+    trivia []Trivia     //Collected since the last token
+    diagnostics []Diagnostic
+    interpolations []interpolation
+}
+
+//How much we read from the reader at a time
+const readSize = 4096
+
+//An open ${ inside a string, we resume the string once its braces balance
+type interpolation struct {
+    quote string;
+    braces int;
 }
 
 func NewScanner(source string) *Scanner {
@@ -20,107 +40,296 @@ func NewScanner(source string) *Scanner {
     scanner.source = source
     scanner.current = 0
     scanner.start = 0
-    scanner.line = 0
+    scanner.line = 1
     //scanner.tokens = make([]Token, 0)
     return scanner
 }
 
-func (scanner *Scanner) Scan() []Token {
+//Scanner that pulls source from reader as tokens are requested
+func NewReaderScanner(reader io.Reader) *Scanner {
+    scanner := NewScanner("")
+    scanner.reader = reader
+    return scanner
+}
 
-    for scanner.notAtEnd() {
+//Keep comments and whitespace as trivia on the tokens around them
+func (scanner *Scanner) SetPreserveTrivia(preserve bool) {
+    scanner.preserveTrivia = preserve
+}
 
-        scanner.start = scanner.current
+//Scan the whole input at once
+func (scanner *Scanner) Scan() ([]Token, []Diagnostic) {
 
-        switch c := scanner.advance(); c {
+    tokens := []Token{}
 
-        case "(":
-            scanner.addToken(LEFT_PAREN)
-        case ")":
-            scanner.addToken(RIGHT_PAREN)
-        case "{":
-            scanner.addToken(LEFT_BRACE)
-        case "}":
-            scanner.addToken(RIGHT_BRACE)
-        case ",":
-            scanner.addToken(COMMA)
-        case ".":
-            scanner.addToken(DOT)
-        case "-":
-            scanner.addToken(MINUS)
-        case "+":
-            scanner.addToken(PLUS)
-        case ";":
-            scanner.addToken(SEMICOLON)
-        case "*":
-            scanner.addToken(STAR)
-        case "!":
-            if scanner.match("=") {
-                scanner.addToken(BANG_EQUAL)
-            } else {
-                scanner.addToken(EQUAL)
-            }
-        case "=":
-            if scanner.match("=") {
-                scanner.addToken(EQUAL_EQUAL)
-            } else {
-                scanner.addToken(EQUAL)
-            }
-        case "<":
-            if scanner.match("=") {
-                scanner.addToken(LESS_EQUAL)
-            } else {
-                scanner.addToken(LESS)
+    for {
+        token := scanner.NextToken()
+        tokens = append(tokens, token)
+
+        if token.TokenType == EOF {
+            break
+        }
+    }
+    return tokens, scanner.diagnostics
+}
+
+//Diagnostics reported so far
+func (scanner *Scanner) Diagnostics() []Diagnostic {
+    return scanner.diagnostics
+}
+
+//Scan and return the next token, EOF is returned once the input is exhausted
+//and for every call after that
+func (scanner *Scanner) NextToken() Token {
+
+    for len(scanner.pending) == 0 {
+
+        //Drop source we have already tokenised
+        scanner.source = scanner.source[scanner.current:]
+        scanner.offset += scanner.current
+        scanner.current = 0
+
+        if scanner.isAtEnd() {
+            scanner.finish()
+        } else {
+            scanner.scanToken()
+        }
+    }
+
+    //EOF stays queued so it is returned again
+    token := scanner.pending[0]
+    if token.TokenType != EOF {
+        if scanner.preserveTrivia {
+            token.TrailingTrivia = scanner.trailingTrivia()
+        }
+        scanner.pending = scanner.pending[1:]
+    }
+    return token
+}
+
+//Scan the trivia that follows a token up to the end of its line. A block
+//comment running onto later lines belongs to the next token instead
+func (scanner *Scanner) trailingTrivia() []Trivia {
+
+    for {
+        next := scanner.peek()
+        comment := next == "/" && (scanner.peekNext() == "/" || scanner.peekNext() == "*")
+
+        if next != " " && next != "\t" && next != "\r" && !comment {
+            break
+        }
+
+        before := len(scanner.trivia)
+        scanner.scanToken()
+
+        if last := scanner.trivia[len(scanner.trivia)-1]; last.Kind == BLOCK_COMMENT && strings.Contains(last.Text, "\n") {
+            trivia := scanner.trivia[:before:before]
+            scanner.trivia = scanner.trivia[before:]
+            return trivia
+        }
+    }
+
+    trivia := scanner.trivia
+    scanner.trivia = nil
+    return trivia
+}
+
+func (scanner *Scanner) finish() {
+
+    if len(scanner.interpolations) > 0 {
+        scanner.error(UNTERMINATED_STRING, "Unterminated string interpolation.")
+        scanner.interpolations = nil
+    }
+
+    scanner.start = scanner.current
+    scanner.startLine = scanner.line
+    scanner.startColumn = scanner.column()
+    scanner.addToken(EOF)
+}
+
+//Scan one lexeme, this may add zero tokens for whitespace and comments
+func (scanner *Scanner) scanToken() {
+
+    scanner.start = scanner.current
+    scanner.startLine = scanner.line
+    scanner.startColumn = scanner.column()
+
+    switch c := scanner.advance(); c {
+
+    case "(":
+        scanner.addToken(LEFT_PAREN)
+    case ")":
+        scanner.addToken(RIGHT_PAREN)
+    case "{":
+        if len(scanner.interpolations) > 0 {
+            scanner.interpolations[len(scanner.interpolations)-1].braces++
+        }
+        scanner.addToken(LEFT_BRACE)
+    case "}":
+        if len(scanner.interpolations) > 0 {
+            top := &scanner.interpolations[len(scanner.interpolations)-1]
+            if top.braces == 0 {
+                //Closes the ${, carry on with the rest of the string
+                scanner.interpolations = scanner.interpolations[:len(scanner.interpolations)-1]
+                scanner.string(top.quote, true)
+                break
             }
-        case ">":
-            if scanner.match("=") {
-                scanner.addToken(GREATER_EQUAL)
-            } else {
-                scanner.addToken(GREATER)
+            top.braces--
+        }
+        scanner.addToken(RIGHT_BRACE)
+    case "[":
+        scanner.addToken(LEFT_BRACKET)
+    case "]":
+        scanner.addToken(RIGHT_BRACKET)
+    case ",":
+        scanner.addToken(COMMA)
+    case ".":
+        if scanner.peek() == "." && scanner.peekNext() == "." {
+            scanner.advance()
+            scanner.advance()
+            scanner.addToken(ELLIPSIS)
+        } else {
+            scanner.addToken(DOT)
+        }
+    case "-":
+        scanner.addToken(MINUS)
+    case "+":
+        scanner.addToken(PLUS)
+    case ";":
+        scanner.addToken(SEMICOLON)
+    case "*":
+        scanner.addToken(STAR)
+    case "?":
+        scanner.addToken(QUESTION)
+    case ":":
+        scanner.addToken(COLON)
+    case "!":
+        if scanner.match("=") {
+            scanner.addToken(BANG_EQUAL)
+        } else {
+            scanner.addToken(BANG)
+        }
+    case "=":
+        if scanner.match("=") {
+            scanner.addToken(EQUAL_EQUAL)
+        } else if scanner.match(">") {
+            scanner.addToken(ARROW)
+        } else {
+            scanner.addToken(EQUAL)
+        }
+    case "<":
+        if scanner.match("=") {
+            scanner.addToken(LESS_EQUAL)
+        } else {
+            scanner.addToken(LESS)
+        }
+    case ">":
+        if scanner.match("=") {
+            scanner.addToken(GREATER_EQUAL)
+        } else {
+            scanner.addToken(GREATER)
+        }
+    case "/":
+        if scanner.match("/") {
+            kind := LINE_COMMENT
+            if scanner.peek() == "/" {
+                kind = DOC_COMMENT
             }
-        case "/":
-            if scanner.match("/") {
-                for scanner.peek() != "\n" && scanner.notAtEnd() {
-                    scanner.advance()
-                }
-            } else {
-                scanner.addToken(SLASH)
+            for scanner.peek() != "\n" && scanner.notAtEnd() {
+                scanner.advance()
             }
-        case " ":
-        case "\r":
-        case "\t":
-        case "\n":
-            scanner.line++
-        case "\"":
-            scanner.string()
-        case "'":
-            scanner.string()
-        default:
-            if scanner.isDigit(c) {
-                scanner.number()
-                //scanner.number()
-            } else if scanner.isAlpha(c) {
-                scanner.identifier()
-            } else {
-                fmt.Print("ERROR")
+            scanner.addTrivia(kind)
+        } else if scanner.match("*") {
+            scanner.blockComment()
+            scanner.addTrivia(BLOCK_COMMENT)
+        } else {
+            scanner.addToken(SLASH)
+        }
+    case " ", "\r", "\t":
+        for scanner.peek() == " " || scanner.peek() == "\r" || scanner.peek() == "\t" {
+            scanner.advance()
+        }
+        scanner.addTrivia(WHITESPACE)
+    case "\n":
+        scanner.newLine()
+        scanner.addTrivia(NEWLINE)
+    case "\"":
+        scanner.string(c, false)
+    case "'":
+        scanner.string(c, false)
+    case "`":
+        scanner.rawString()
+    default:
+        if scanner.isDigit(c) {
+            scanner.number()
+            //scanner.number()
+        } else if scanner.isAlpha(c) {
+            scanner.identifier()
+        } else if !utf8.ValidString(c) {
+            //Already reported by advance
+        } else {
+            scanner.error(UNEXPECTED_CHARACTER, "Unexpected character.")
+        }
+    }
+}
+
+//Buffer at least n bytes past current, unless the reader runs out first
+func (scanner *Scanner) fill(n int) {
+
+    for scanner.reader != nil && len(scanner.source)-scanner.current < n {
+
+        chunk := make([]byte, readSize)
+        read, error := scanner.reader.Read(chunk)
+        scanner.source += string(chunk[:read])
+
+        if error != nil {
+            if error != io.EOF {
+                scanner.report(Diagnostic{Kind: READ_ERROR, Message: error.Error(),
+                    Line: scanner.line, Column: scanner.column()})
             }
+            scanner.reader = nil
         }
     }
-    scanner.addToken(EOF)
-    return scanner.tokens
 }
 
+//Consume one UTF-8 encoded rune, invalid bytes are consumed one at a time
 func (scanner *Scanner) advance() string {
 
-    val := string(scanner.source[scanner.current])
-    scanner.current++
+    scanner.fill(utf8.UTFMax)
+    r, size := utf8.DecodeRuneInString(scanner.source[scanner.current:])
+    val := scanner.source[scanner.current:scanner.current+size]
+
+    if r == utf8.RuneError && size == 1 {
+        scanner.report(Diagnostic{Kind: INVALID_UTF8, Message: "Invalid UTF-8 encoding.",
+            Line: scanner.line, Column: scanner.column(), Lexeme: val})
+    }
+
+    scanner.current += size
+    scanner.lineColumn++
     return val
 }
 
 func (scanner *Scanner) addTokenLiteral(tokenType TokenType, literal interface{}) {
 
     text := scanner.source[scanner.start:scanner.current]
-    newToken :=Token{TokenType:tokenType, Lexeme: text, Literal: literal, Line:scanner.line}
-    scanner.tokens = append(scanner.tokens, newToken)
+    newToken := Token{TokenType: tokenType, Lexeme: text, Literal: literal, Line: scanner.startLine,
+        Column: scanner.startColumn, Start: scanner.offset + scanner.start, End: scanner.offset + scanner.current,
+        LeadingTrivia: scanner.trivia}
+    scanner.trivia = nil
+    scanner.pending = append(scanner.pending, newToken)
+}
+
+//Record the lexeme just scanned as trivia when preserving it
+func (scanner *Scanner) addTrivia(kind TriviaKind) {
+
+    if !scanner.preserveTrivia {
+        return
+    }
+
+    text := scanner.source[scanner.start:scanner.current]
+    trivia := Trivia{Kind: kind, Text: text, Line: scanner.startLine, Column: scanner.startColumn,
+        Start: scanner.offset + scanner.start, End: scanner.offset + scanner.current}
+    scanner.trivia = append(scanner.trivia, trivia)
 }
 
 func (scanner *Scanner) addToken(tokenType TokenType) {
@@ -131,77 +340,268 @@ func (scanner *Scanner) addToken(tokenType TokenType) {
 //Match next character and consume
 func (scanner *Scanner) match(expected string) bool {
 
-    if (!scanner.notAtEnd()) {
-        return false
-    }
-
-    if (string(scanner.source[scanner.current]) != expected) {
+    if (scanner.peek() != expected) {
         return false //Not the character we want, exit
     }
 
     //Consume the expected token
-    scanner.current++
+    scanner.advance()
     return true
 }
 
 //Lookahead one character
 func (scanner *Scanner) peek() string {
 
+    scanner.fill(utf8.UTFMax)
     if scanner.isAtEnd() {
         return ""
     }
-    return string(scanner.source[scanner.current])
+    _, size := utf8.DecodeRuneInString(scanner.source[scanner.current:])
+    return scanner.source[scanner.current:scanner.current+size]
 }
 
 func (scanner *Scanner) peekNext() string {
 
-    if scanner.current + 1 >= len(scanner.source) {
+    scanner.fill(2 * utf8.UTFMax)
+    if scanner.isAtEnd() {
+        return ""
+    }
+    _, size := utf8.DecodeRuneInString(scanner.source[scanner.current:])
+    next := scanner.current + size
+
+    if next >= len(scanner.source) {
         return ""
     }
 
-    return string(scanner.source[scanner.current+1])
+    _, nextSize := utf8.DecodeRuneInString(scanner.source[next:])
+    return scanner.source[next:next+nextSize]
 }
 
+//Identifiers may continue with any Unicode letter, digit or combining mark
 func (scanner *Scanner) isAlphaNumeric(char string) bool {
-    return scanner.isAlpha(char) || scanner.isDigit(char)
+
+    if scanner.isAlpha(char) || scanner.isDigit(char) {
+        return true
+    }
+
+    r, _ := utf8.DecodeRuneInString(char)
+    return r != utf8.RuneError && (unicode.IsDigit(r) || unicode.IsMark(r))
 }
 
+//Number literals only start with ASCII digits
 func (scanner *Scanner) isDigit(char string) bool {
-    _, error := strconv.Atoi(char)
 
-    return error == nil
+    return len(char) == 1 && char[0] >= '0' && char[0] <= '9'
 }
 
 func (scanner *Scanner) isAlpha(char string) bool {
 
-    for _, c := range char {
-        return unicode.IsLetter(c) || c == '_'
-    }
-    return false
+    r, _ := utf8.DecodeRuneInString(char)
+    return r != utf8.RuneError && (unicode.IsLetter(r) || r == '_')
 }
 
+//Match string until the matching quote, decoding escape sequences.
+//A ${ ends the current part as an INTERPOLATION token, the expression tokens
+//follow and the string resumes at the matching }. Resumed parts are
+//INTERPOLATION_MIDDLE or INTERPOLATION_END so they never read as a new string
+func (scanner *Scanner) string(quote string, resumed bool) {
+
+    part, end := INTERPOLATION, STRING
+    if resumed {
+        part, end = INTERPOLATION_MIDDLE, INTERPOLATION_END
+    }
+
+    var value strings.Builder
+
+    for scanner.peek() != quote && scanner.notAtEnd() {
+
+        c := scanner.advance()
+
+        switch c {
+        case "\n":
+            scanner.newLine()
+            value.WriteString(c)
+        case "\\":
+            scanner.escape(&value)
+        case "$":
+            if scanner.match("{") {
+                scanner.interpolations = append(scanner.interpolations, interpolation{quote: quote})
+                scanner.addTokenLiteral(part, value.String())
+                return
+            }
+            value.WriteString(c)
+        default:
+            value.WriteString(c)
+        }
+    }
+
+    if (scanner.isAtEnd()) {
+        scanner.unterminated(UNTERMINATED_STRING, "Unterminated string.")
+        return
+    }
+
+    scanner.advance()
+
+    scanner.addTokenLiteral(end, value.String())
+}
 
-//Match string until ' or "
-func (scanner *Scanner) string() {
+//Match a backtick string verbatim, escapes and ${ are not special. When the
+//opening backtick ends its line the text is treated as an indented block:
+//the first newline, the closing line and the common indentation are removed
+func (scanner *Scanner) rawString() {
 
-    for scanner.peek() != "'" && scanner.peek() != "\"" && scanner.notAtEnd() {
-        if scanner.peek() == "\n" {
-            scanner.line++
+    for scanner.peek() != "`" && scanner.notAtEnd() {
+        if scanner.advance() == "\n" {
+            scanner.newLine()
         }
-        scanner.advance();
     }
 
     if (scanner.isAtEnd()) {
-        fmt.Println("Unterminated string")
+        scanner.unterminated(UNTERMINATED_STRING, "Unterminated raw string.")
         return
     }
 
     scanner.advance()
 
     value := scanner.source[scanner.start+1:scanner.current-1]
+    if strings.HasPrefix(value, "\n") || strings.HasPrefix(value, "\r\n") {
+        value = scanner.dedent(value)
+    }
     scanner.addTokenLiteral(STRING, value)
 }
 
+func (scanner *Scanner) dedent(value string) string {
+
+    lines := strings.Split(value, "\n")[1:]
+
+    //The closing backtick on its own line isn't part of the text
+    if last := lines[len(lines)-1]; strings.TrimSpace(last) == "" {
+        lines = lines[:len(lines)-1]
+    }
+
+    indent := ""
+    first := true
+    for _, line := range lines {
+        if strings.TrimSpace(line) == "" {
+            continue
+        }
+
+        lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+        if first {
+            indent = lineIndent
+            first = false
+        }
+
+        //Shrink to the common prefix
+        for !strings.HasPrefix(lineIndent, indent) {
+            indent = indent[:len(indent)-1]
+        }
+    }
+
+    for i, line := range lines {
+        lines[i] = strings.TrimPrefix(line, indent)
+    }
+    return strings.Join(lines, "\n")
+}
+
+//Skip a /* */ comment, these nest so every /* needs its own */
+func (scanner *Scanner) blockComment() {
+
+    depth := 1
+
+    for depth > 0 && scanner.notAtEnd() {
+
+        c := scanner.advance()
+
+        if c == "\n" {
+            scanner.newLine()
+        } else if c == "/" && scanner.match("*") {
+            depth++
+        } else if c == "*" && scanner.match("/") {
+            depth--
+        }
+    }
+
+    if depth > 0 {
+        scanner.unterminated(UNTERMINATED_COMMENT, "Unterminated block comment.")
+    }
+}
+
+//Decode the escape sequence following a backslash into value
+func (scanner *Scanner) escape(value *strings.Builder) {
+
+    escapeStart := scanner.current - 1
+    column := scanner.column() - 1
+
+    if scanner.isAtEnd() {
+        return //Reported as an unterminated string
+    }
+
+    switch c := scanner.advance(); c {
+    case "n":
+        value.WriteString("\n")
+    case "t":
+        value.WriteString("\t")
+    case "r":
+        value.WriteString("\r")
+    case "0":
+        value.WriteString("\x00")
+    case "\\", "\"", "'", "$":
+        value.WriteString(c)
+    case "x":
+        digits := scanner.hexDigits(2)
+        code, err := strconv.ParseUint(digits, 16, 8)
+        if len(digits) != 2 || err != nil {
+            scanner.invalidEscape(escapeStart, column, "Expected two hex digits after \\x.")
+            return
+        }
+        //Higher bytes would make the string invalid UTF-8, \u{} covers those characters
+        if code > 0x7F {
+            scanner.invalidEscape(escapeStart, column, "\\x escapes must be at most 7F, use \\u{} for other characters.")
+            return
+        }
+        value.WriteByte(byte(code))
+    case "u":
+        if !scanner.match("{") {
+            scanner.invalidEscape(escapeStart, column, "Expected '{' after \\u.")
+            return
+        }
+        digits := scanner.hexDigits(6)
+        code, err := strconv.ParseUint(digits, 16, 32)
+        if !scanner.match("}") || len(digits) == 0 || err != nil || !utf8.ValidRune(rune(code)) {
+            scanner.invalidEscape(escapeStart, column, "Invalid unicode escape.")
+            return
+        }
+        value.WriteRune(rune(code))
+    default:
+        if c == "\n" {
+            scanner.newLine()
+        }
+        scanner.invalidEscape(escapeStart, column, "Unknown escape sequence.")
+    }
+}
+
+//Consume up to max hex digits
+func (scanner *Scanner) hexDigits(max int) string {
+
+    start := scanner.current
+    for scanner.current-start < max && scanner.isHexDigit(scanner.peek()) {
+        scanner.advance()
+    }
+    return scanner.source[start:scanner.current]
+}
+
+func (scanner *Scanner) isHexDigit(char string) bool {
+    return len(char) == 1 && strings.Contains("0123456789abcdefABCDEF", char)
+}
+
+func (scanner *Scanner) invalidEscape(escapeStart int, column int, message string) {
+
+    lexeme := scanner.source[escapeStart:scanner.current]
+    scanner.report(Diagnostic{Kind: INVALID_ESCAPE, Message: message, Line: scanner.line,
+        Column: column, Lexeme: lexeme})
+}
+
 func (scanner *Scanner) identifier() {
 
     for scanner.isAlphaNumeric(scanner.peek()) {
@@ -218,31 +618,167 @@ func (scanner *Scanner) identifier() {
     scanner.addToken(tokenType)
 }
 
+//Decimal numbers with optional fraction and exponent, or 0x/0b/0o integers.
+//Digits may be separated with _ as in 1_000_000
 func (scanner *Scanner) number() {
 
-    for scanner.isDigit(scanner.peek()) {
-        scanner.advance()
+    if scanner.source[scanner.start:scanner.current] == "0" {
+        switch scanner.peek() {
+        case "x", "X":
+            scanner.advance()
+            scanner.radixNumber(16, "hexadecimal")
+            return
+        case "b", "B":
+            scanner.advance()
+            scanner.radixNumber(2, "binary")
+            return
+        case "o", "O":
+            scanner.advance()
+            scanner.radixNumber(8, "octal")
+            return
+        }
     }
 
+    scanner.decimalDigits()
+
     if scanner.peek() == "." && scanner.isDigit(scanner.peekNext()) {
         scanner.advance()
+        scanner.decimalDigits()
+    }
+
+    if scanner.peek() == "e" || scanner.peek() == "E" {
+        scanner.advance()
 
-        for scanner.isDigit(scanner.peek()) {
+        if scanner.peek() == "+" || scanner.peek() == "-" {
             scanner.advance()
         }
+
+        if !scanner.isDigit(scanner.peek()) {
+            scanner.error(INVALID_NUMBER, "Expected digits in exponent.")
+            return
+        }
+        scanner.decimalDigits()
+    }
+
+    text := scanner.source[scanner.start:scanner.current]
+    if !scanner.validSeparators(text, 10) {
+        scanner.error(INVALID_NUMBER, "Digit separator '_' must be between digits.")
+        return
     }
 
-    float,error := strconv.ParseFloat(scanner.source[scanner.start:scanner.current],64)
+    float,error := strconv.ParseFloat(strings.ReplaceAll(text, "_", ""), 64)
 
     if (error != nil) {
-        fmt.Print("ERROR cannot parse float " + scanner.source[scanner.start:scanner.current])
+        scanner.error(INVALID_NUMBER, "Cannot parse number.")
+        return
     }
     scanner.addTokenLiteral(NUMBER, float)
 
 }
 
+func (scanner *Scanner) decimalDigits() {
+
+    for scanner.isDigit(scanner.peek()) || scanner.peek() == "_" {
+        scanner.advance()
+    }
+}
+
+//Integer after a 0x, 0b or 0o prefix
+func (scanner *Scanner) radixNumber(base int, name string) {
+
+    //Consume anything identifier-like so 0b102 is one bad literal, not two tokens
+    for scanner.isAlphaNumeric(scanner.peek()) {
+        scanner.advance()
+    }
+
+    digits := scanner.source[scanner.start+2:scanner.current]
+
+    if len(digits) == 0 {
+        scanner.error(INVALID_NUMBER, "Expected "+name+" digits.")
+        return
+    }
+
+    for _, c := range digits {
+        if c != '_' && !scanner.isDigitOf(string(c), base) {
+            scanner.error(INVALID_NUMBER, "Invalid "+name+" digit '"+string(c)+"'.")
+            return
+        }
+    }
+
+    if !scanner.validSeparators(digits, base) {
+        scanner.error(INVALID_NUMBER, "Digit separator '_' must be between digits.")
+        return
+    }
+
+    value, error := strconv.ParseUint(strings.ReplaceAll(digits, "_", ""), base, 64)
+
+    if (error != nil) {
+        scanner.error(INVALID_NUMBER, "Number is too large.")
+        return
+    }
+    scanner.addTokenLiteral(NUMBER, float64(value))
+}
+
+//Every _ must have a digit on both sides
+func (scanner *Scanner) validSeparators(text string, base int) bool {
+
+    for i := 0; i < len(text); i++ {
+        if text[i] != '_' {
+            continue
+        }
+        if i == 0 || i == len(text)-1 {
+            return false
+        }
+        if !scanner.isDigitOf(text[i-1:i], base) || !scanner.isDigitOf(text[i+1:i+2], base) {
+            return false
+        }
+    }
+    return true
+}
+
+func (scanner *Scanner) isDigitOf(char string, base int) bool {
+    _, error := strconv.ParseUint(char, base, 8)
+
+    return len(char) == 1 && error == nil
+}
+
+//The input ended early, any open ${ are unterminated for the same reason so
+//aren't reported again
+func (scanner *Scanner) unterminated(kind DiagnosticKind, message string) {
+    scanner.error(kind, message)
+    scanner.interpolations = nil
+}
+
+//Record a diagnostic for the lexeme currently being scanned
+func (scanner *Scanner) error(kind DiagnosticKind, message string) {
+
+    lexeme := scanner.source[scanner.start:scanner.current]
+    scanner.report(Diagnostic{Kind: kind, Message: message, Line: scanner.startLine,
+        Column: scanner.startColumn, Lexeme: lexeme})
+}
+
+func (scanner *Scanner) report(diagnostic Diagnostic) {
+
+    scanner.diagnostics = append(scanner.diagnostics, diagnostic)
+}
+
+//Called after consuming a newline character
+func (scanner *Scanner) newLine() {
+
+    scanner.line++
+    scanner.lineColumn = 0
+}
+
+//1-based column of the current position, counted in runes
+func (scanner *Scanner) column() int {
+
+    return scanner.lineColumn + 1
+}
+
 func (scanner *Scanner) notAtEnd() bool {
 
+    scanner.fill(1)
+
     return scanner.current < len(scanner.source)
 }
 func (scanner *Scanner) isAtEnd() bool {
@@ -0,0 +1,411 @@
+package lexer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	. "github.com/elliotthill/golox/language"
+)
+
+type DiagnosticTest struct {
+    name     string
+    source   string
+    expected []DiagnosticKind
+}
+
+var (
+    diagnosticTests []DiagnosticTest = []DiagnosticTest{
+        {name: "Clean", source: "var x = 1;", expected: []DiagnosticKind{}},
+        {name: "Unexpected character", source: "var x = 1 @ 2;", expected: []DiagnosticKind{UNEXPECTED_CHARACTER}},
+        {name: "Several characters", source: "# $", expected: []DiagnosticKind{UNEXPECTED_CHARACTER, UNEXPECTED_CHARACTER}},
+        {name: "Unterminated string", source: "print 'abc", expected: []DiagnosticKind{UNTERMINATED_STRING}},
+        {name: "Mismatched quotes", source: "print 'abc\";", expected: []DiagnosticKind{UNTERMINATED_STRING}},
+        {name: "Unknown escape", source: "'\\q'", expected: []DiagnosticKind{INVALID_ESCAPE}},
+        {name: "Short hex escape", source: "'\\x4'", expected: []DiagnosticKind{INVALID_ESCAPE}},
+        {name: "Non-ASCII hex escape", source: "'\\xff'", expected: []DiagnosticKind{INVALID_ESCAPE}},
+        {name: "Unicode without braces", source: "'\\u41'", expected: []DiagnosticKind{INVALID_ESCAPE}},
+        {name: "Unterminated interpolation", source: "'a ${b", expected: []DiagnosticKind{UNTERMINATED_STRING}},
+        {name: "Unterminated string in interpolation", source: "'a ${\"b", expected: []DiagnosticKind{UNTERMINATED_STRING}},
+        {name: "Block comment", source: "/* @ # */ var x;", expected: []DiagnosticKind{}},
+        {name: "Nested block comment", source: "/* a /* b */ @ */ var x;", expected: []DiagnosticKind{}},
+        {name: "Unterminated comment", source: "/* a /* b */ c", expected: []DiagnosticKind{UNTERMINATED_COMMENT}},
+        {name: "Empty hex", source: "0x;", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Bad binary digit", source: "0b102", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Bad octal digit", source: "0o78", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Empty exponent", source: "1e;", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Signed empty exponent", source: "1e+", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Trailing separator", source: "1_000_", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Double separator", source: "1__0", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Separator before fraction", source: "1_.5", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Hex overflow", source: "0x1_0000_0000_0000_0000", expected: []DiagnosticKind{INVALID_NUMBER}},
+        {name: "Invalid UTF-8", source: "var x = \xff;", expected: []DiagnosticKind{INVALID_UTF8}},
+        {name: "Invalid UTF-8 in string", source: "'a\xc3'", expected: []DiagnosticKind{INVALID_UTF8}},
+        {name: "Unicode symbol", source: "var x = 1 € 2;", expected: []DiagnosticKind{UNEXPECTED_CHARACTER}},
+        {name: "Unterminated raw string", source: "`abc", expected: []DiagnosticKind{UNTERMINATED_STRING}},
+        {name: "Raw string ignores escapes", source: "`\\q ${`", expected: []DiagnosticKind{}},
+        {name: "Unicode out of range", source: "'\\u{110000}'", expected: []DiagnosticKind{INVALID_ESCAPE}},
+    }
+)
+
+func TestScanDiagnostics(t *testing.T) {
+
+    for _, test := range diagnosticTests {
+
+        _, diagnostics := NewScanner(test.source).Scan()
+
+        if len(diagnostics) != len(test.expected) {
+            t.Errorf("%s: got %d diagnostics %v, expected %d", test.name, len(diagnostics), diagnostics, len(test.expected))
+            continue
+        }
+
+        for i, diagnostic := range diagnostics {
+            if diagnostic.Kind != test.expected[i] {
+                t.Errorf("%s: got %s, expected %s", test.name, diagnostic.Kind, test.expected[i])
+            }
+        }
+    }
+}
+
+func TestDiagnosticPosition(t *testing.T) {
+
+    _, diagnostics := NewScanner("var x;\n  @").Scan()
+
+    if len(diagnostics) != 1 {
+        t.Fatalf("Got %d diagnostics, expected 1", len(diagnostics))
+    }
+
+    diagnostic := diagnostics[0]
+    if diagnostic.Line != 2 || diagnostic.Column != 3 || diagnostic.Lexeme != "@" {
+        t.Errorf("Got %s, expected line 2 column 3 lexeme '@'", diagnostic)
+    }
+}
+
+type PositionTest struct {
+    lexeme string
+    line   int
+    column int
+    start  int
+    end    int
+}
+
+func TestTokenPositions(t *testing.T) {
+
+    tokens, _ := NewScanner("var x = 10;\nprint 'a\nb' ;").Scan()
+
+    expected := []PositionTest{
+        {lexeme: "var", line: 1, column: 1, start: 0, end: 3},
+        {lexeme: "x", line: 1, column: 5, start: 4, end: 5},
+        {lexeme: "=", line: 1, column: 7, start: 6, end: 7},
+        {lexeme: "10", line: 1, column: 9, start: 8, end: 10},
+        {lexeme: ";", line: 1, column: 11, start: 10, end: 11},
+        {lexeme: "print", line: 2, column: 1, start: 12, end: 17},
+        {lexeme: "'a\nb'", line: 2, column: 7, start: 18, end: 23},
+        {lexeme: ";", line: 3, column: 4, start: 24, end: 25},
+        {lexeme: "", line: 3, column: 5, start: 25, end: 25},
+    }
+
+    if len(tokens) != len(expected) {
+        t.Fatalf("Got %d tokens, expected %d", len(tokens), len(expected))
+    }
+
+    for i, token := range tokens {
+        want := expected[i]
+        got := PositionTest{lexeme: token.Lexeme, line: token.Line, column: token.Column, start: token.Start, end: token.End}
+        if got != want {
+            t.Errorf("Token %d: got %+v, expected %+v", i, got, want)
+        }
+    }
+}
+
+func TestEllipsis(t *testing.T) {
+
+    tokens, _ := NewScanner("...rest a.b ..").Scan()
+
+    expected := []TokenType{ELLIPSIS, IDENTIFIER, IDENTIFIER, DOT, IDENTIFIER, DOT, DOT, EOF}
+    got := []TokenType{}
+    for _, token := range tokens {
+        got = append(got, token.TokenType)
+    }
+
+    if !reflect.DeepEqual(got, expected) {
+        t.Errorf("Got %v, expected %v", got, expected)
+    }
+}
+
+type StringTest struct {
+    source   string
+    expected string
+}
+
+func TestStringLiterals(t *testing.T) {
+
+    tests := []StringTest{
+        {source: `'plain'`, expected: "plain"},
+        {source: `"it's"`, expected: "it's"},
+        {source: `'say "hi"'`, expected: `say "hi"`},
+        {source: `'a\nb\tc'`, expected: "a\nb\tc"},
+        {source: `'back\\slash'`, expected: `back\slash`},
+        {source: `"\"quoted\""`, expected: `"quoted"`},
+        {source: `'\'single\''`, expected: "'single'"},
+        {source: `'\x41\x62'`, expected: "Ab"},
+        {source: `'\u{48}\u{e9}\u{1F600}'`, expected: "H\u00e9\U0001F600"},
+        {source: "`raw \\n ${x} 'q' \"q\"`", expected: `raw \n ${x} 'q' "q"`},
+        {source: "`  keep\n    indent`", expected: "  keep\n    indent"},
+        {source: "`\n    SELECT *\n      FROM t\n\n    WHERE x\n    `", expected: "SELECT *\n  FROM t\n\nWHERE x"},
+        {source: "`\n\tone\n\t\ttwo`", expected: "one\n\ttwo"},
+    }
+
+    for _, test := range tests {
+
+        tokens, diagnostics := NewScanner(test.source).Scan()
+
+        if len(diagnostics) > 0 {
+            t.Errorf("%s: unexpected diagnostics %v", test.source, diagnostics)
+            continue
+        }
+        if tokens[0].Literal != test.expected {
+            t.Errorf("%s: got %q, expected %q", test.source, tokens[0].Literal, test.expected)
+        }
+    }
+}
+
+func TestInterpolationTokens(t *testing.T) {
+
+    tokens, diagnostics := NewScanner(`"a ${x + "}"} b ${y} c"`).Scan()
+
+    if len(diagnostics) > 0 {
+        t.Fatalf("Unexpected diagnostics %v", diagnostics)
+    }
+
+    expected := []TokenType{INTERPOLATION, IDENTIFIER, PLUS, STRING, INTERPOLATION_MIDDLE, IDENTIFIER, INTERPOLATION_END, EOF}
+    if len(tokens) != len(expected) {
+        t.Fatalf("Got %d tokens %v, expected %d", len(tokens), tokens, len(expected))
+    }
+
+    for i, token := range tokens {
+        if token.TokenType != expected[i] {
+            t.Errorf("Token %d: got %s, expected %s", i, token.TokenType, expected[i])
+        }
+    }
+
+    if tokens[0].Literal != "a " || tokens[3].Literal != "}" || tokens[4].Literal != " b " || tokens[6].Literal != " c" {
+        t.Errorf("Unexpected string parts %v", tokens)
+    }
+}
+
+func TestBlockCommentLines(t *testing.T) {
+
+    tokens, _ := NewScanner("/* one\n/* two\n*/ three\n*/ x").Scan()
+
+    if tokens[0].Lexeme != "x" || tokens[0].Line != 4 || tokens[0].Column != 4 {
+        t.Errorf("Got %s, expected x at 4:4", &tokens[0])
+    }
+}
+
+type NumberTest struct {
+    source   string
+    expected float64
+}
+
+func TestNumberLiterals(t *testing.T) {
+
+    tests := []NumberTest{
+        {source: "123", expected: 123},
+        {source: "1.5", expected: 1.5},
+        {source: "0xFF", expected: 255},
+        {source: "0Xff", expected: 255},
+        {source: "0b1010", expected: 10},
+        {source: "0o755", expected: 493},
+        {source: "1e-9", expected: 1e-9},
+        {source: "2.5E10", expected: 2.5e10},
+        {source: "1e+3", expected: 1000},
+        {source: "1_000_000", expected: 1000000},
+        {source: "0xFF_FF", expected: 65535},
+        {source: "0", expected: 0},
+    }
+
+    for _, test := range tests {
+
+        tokens, diagnostics := NewScanner(test.source).Scan()
+
+        if len(diagnostics) > 0 {
+            t.Errorf("%s: unexpected diagnostics %v", test.source, diagnostics)
+            continue
+        }
+        if len(tokens) != 2 || tokens[0].Literal != test.expected {
+            t.Errorf("%s: got %v, expected %v", test.source, tokens, test.expected)
+        }
+    }
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+
+    tokens, diagnostics := NewScanner("var größe = '变量'; 变量2 = größe;").Scan()
+
+    if len(diagnostics) > 0 {
+        t.Fatalf("Unexpected diagnostics %v", diagnostics)
+    }
+
+    expected := []PositionTest{
+        {lexeme: "var", line: 1, column: 1, start: 0, end: 3},
+        {lexeme: "größe", line: 1, column: 5, start: 4, end: 11},
+        {lexeme: "=", line: 1, column: 11, start: 12, end: 13},
+        {lexeme: "'变量'", line: 1, column: 13, start: 14, end: 22},
+        {lexeme: ";", line: 1, column: 17, start: 22, end: 23},
+        {lexeme: "变量2", line: 1, column: 19, start: 24, end: 31},
+    }
+
+    for i, want := range expected {
+        token := tokens[i]
+        got := PositionTest{lexeme: token.Lexeme, line: token.Line, column: token.Column, start: token.Start, end: token.End}
+        if got != want {
+            t.Errorf("Token %d: got %+v, expected %+v", i, got, want)
+        }
+    }
+
+    if tokens[1].TokenType != IDENTIFIER || tokens[5].TokenType != IDENTIFIER || tokens[3].Literal != "变量" {
+        t.Errorf("Unexpected tokens %v", tokens)
+    }
+}
+
+func TestReaderScannerMatchesScan(t *testing.T) {
+
+    source := "var größe = 0xFF;\n/* block\n comment */ print 'a ${größe} \\u{1F600}';\n# @"
+
+    expectedTokens, expectedDiagnostics := NewScanner(source).Scan()
+
+    //One byte at a time splits multi-byte runes across reads
+    scanner := NewReaderScanner(iotest.OneByteReader(strings.NewReader(source)))
+    tokens := []Token{}
+
+    for token := scanner.NextToken(); ; token = scanner.NextToken() {
+        tokens = append(tokens, token)
+        if token.TokenType == EOF {
+            break
+        }
+    }
+
+    if !reflect.DeepEqual(tokens, expectedTokens) {
+        t.Errorf("Got tokens %v, expected %v", tokens, expectedTokens)
+    }
+    if !reflect.DeepEqual(scanner.Diagnostics(), expectedDiagnostics) {
+        t.Errorf("Got diagnostics %v, expected %v", scanner.Diagnostics(), expectedDiagnostics)
+    }
+
+    if eof := scanner.NextToken(); eof.TokenType != EOF {
+        t.Errorf("Got %s after EOF, expected EOF", &eof)
+    }
+}
+
+func TestReaderScannerError(t *testing.T) {
+
+    scanner := NewReaderScanner(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("var x;"))))
+    tokens, diagnostics := scanner.Scan()
+
+    if len(diagnostics) != 1 || diagnostics[0].Kind != READ_ERROR {
+        t.Errorf("Got diagnostics %v, expected one READ_ERROR", diagnostics)
+    }
+    if tokens[len(tokens)-1].TokenType != EOF {
+        t.Errorf("Expected token stream to end with EOF, got %v", tokens)
+    }
+}
+
+func TestRawStringLines(t *testing.T) {
+
+    tokens, _ := NewScanner("`a\nb\nc` x").Scan()
+
+    if tokens[1].Lexeme != "x" || tokens[1].Line != 3 || tokens[1].Column != 4 {
+        t.Errorf("Got %s, expected x at 3:4", &tokens[1])
+    }
+}
+
+type TriviaTest struct {
+    kind TriviaKind
+    text string
+}
+
+func triviaOf(trivia []Trivia) []TriviaTest {
+
+    result := []TriviaTest{}
+    for _, t := range trivia {
+        result = append(result, TriviaTest{kind: t.Kind, text: t.Text})
+    }
+    return result
+}
+
+func TestTrivia(t *testing.T) {
+
+    source := "/// Adds one\nvar x = 1; // trailing\n/* block */ print x;"
+    scanner := NewScanner(source)
+    scanner.SetPreserveTrivia(true)
+    tokens, diagnostics := scanner.Scan()
+
+    if len(diagnostics) > 0 {
+        t.Fatalf("Unexpected diagnostics %v", diagnostics)
+    }
+
+    leading := triviaOf(tokens[0].LeadingTrivia)
+    expected := []TriviaTest{{kind: DOC_COMMENT, text: "/// Adds one"}, {kind: NEWLINE, text: "\n"}}
+    if !reflect.DeepEqual(leading, expected) {
+        t.Errorf("var: got leading %v, expected %v", leading, expected)
+    }
+
+    trailing := triviaOf(tokens[4].TrailingTrivia)
+    expected = []TriviaTest{{kind: WHITESPACE, text: " "}, {kind: LINE_COMMENT, text: "// trailing"}}
+    if !reflect.DeepEqual(trailing, expected) {
+        t.Errorf("';': got trailing %v, expected %v", trailing, expected)
+    }
+
+    leading = triviaOf(tokens[5].LeadingTrivia)
+    expected = []TriviaTest{{kind: NEWLINE, text: "\n"}, {kind: BLOCK_COMMENT, text: "/* block */"}, {kind: WHITESPACE, text: " "}}
+    if tokens[5].Lexeme != "print" || !reflect.DeepEqual(leading, expected) {
+        t.Errorf("print: got leading %v, expected %v", leading, expected)
+    }
+
+    //Every byte of the source is either a token or trivia
+    var rebuilt strings.Builder
+    for _, token := range tokens {
+        for _, trivia := range token.LeadingTrivia {
+            rebuilt.WriteString(trivia.Text)
+        }
+        rebuilt.WriteString(token.Lexeme)
+        for _, trivia := range token.TrailingTrivia {
+            rebuilt.WriteString(trivia.Text)
+        }
+    }
+    if rebuilt.String() != source {
+        t.Errorf("Got %q rebuilding source, expected %q", rebuilt.String(), source)
+    }
+}
+
+func TestMultilineCommentTrivia(t *testing.T) {
+
+    scanner := NewScanner("a /* x\n y */ b")
+    scanner.SetPreserveTrivia(true)
+    tokens, _ := scanner.Scan()
+
+    trailing := triviaOf(tokens[0].TrailingTrivia)
+    expected := []TriviaTest{{kind: WHITESPACE, text: " "}}
+    if !reflect.DeepEqual(trailing, expected) {
+        t.Errorf("a: got trailing %v, expected %v", trailing, expected)
+    }
+
+    leading := triviaOf(tokens[1].LeadingTrivia)
+    expected = []TriviaTest{{kind: BLOCK_COMMENT, text: "/* x\n y */"}, {kind: WHITESPACE, text: " "}}
+    if !reflect.DeepEqual(leading, expected) {
+        t.Errorf("b: got leading %v, expected %v", leading, expected)
+    }
+}
+
+func TestTriviaOffByDefault(t *testing.T) {
+
+    tokens, _ := NewScanner("// comment\nvar x; // trailing").Scan()
+
+    for _, token := range tokens {
+        if token.LeadingTrivia != nil || token.TrailingTrivia != nil {
+            t.Errorf("Got trivia on %s without asking for it", &token)
+        }
+    }
+}
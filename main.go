@@ -11,6 +11,7 @@ import (
 	"github.com/elliotthill/golox/interpreter"
 	"github.com/elliotthill/golox/lexer"
 	"github.com/elliotthill/golox/parser"
+	"github.com/elliotthill/golox/resolver"
 )
 
 
@@ -38,7 +39,10 @@ func main() {
 			return
 		}
 
-		Run(sourceCode, interpreter.NewInterpreter(defaultOut, defaultErr), debug)
+		exitCode := Run(sourceCode, interpreter.NewInterpreter(defaultOut, defaultErr), debug)
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
 
 	} else {
 
@@ -47,10 +51,17 @@ func main() {
 
 }
 
-func Run(source string, interpreter *interpreter.Interpreter, debug bool) {
+// Exit codes returned by Run
+const (
+	exitOK           = 0
+	exitDataError    = 65 //Source could not be lexed, parsed or resolved
+	exitRuntimeError = 70 //An error was raised and nothing caught it
+)
+
+func Run(source string, interpreter *interpreter.Interpreter, debug bool) int {
 
 	scanner := lexer.NewScanner(source)
-	tokens := scanner.Scan()
+	tokens, diagnostics := scanner.Scan()
 
 	if debug {
 
@@ -61,8 +72,15 @@ func Run(source string, interpreter *interpreter.Interpreter, debug bool) {
 		}
 	}
 
+	if len(diagnostics) > 0 {
+		for _, diagnostic := range diagnostics {
+			fmt.Fprintln(defaultErr, diagnostic)
+		}
+		return exitDataError
+	}
+
 	parser := parser.NewParser(tokens)
-	statements := parser.Parse()
+	statements, parseErrors := parser.Parse()
 
 	if debug {
 		fmt.Println("== Parse Tree ==")
@@ -74,9 +92,37 @@ func Run(source string, interpreter *interpreter.Interpreter, debug bool) {
 		fmt.Println("== Interp ==")
 	}
 
-    interpreter.SetStatements(statements);
-	interpreter.Interpret()
+	if len(parseErrors) > 0 {
+		for _, parseError := range parseErrors {
+			fmt.Fprintln(defaultErr, parseError)
+		}
+		return exitDataError
+	}
 
+	resolve := resolver.NewResolver(interpreter.Globals())
+	locals, resolveErrors := resolve.Resolve(statements)
+
+	for _, warning := range resolve.Warnings() {
+		fmt.Fprintln(defaultErr, "Warning:", warning)
+	}
+
+	if len(resolveErrors) > 0 {
+		for _, resolveError := range resolveErrors {
+			fmt.Fprintln(defaultErr, resolveError)
+		}
+		return exitDataError
+	}
+
+	interpreter.SetLocals(locals)
+    interpreter.SetStatements(statements);
+	if runtimeError := interpreter.Interpret(); runtimeError != nil {
+		fmt.Fprintln(defaultErr, runtimeError)
+		for _, frame := range runtimeError.Stack {
+			fmt.Fprintln(defaultErr, "    "+frame)
+		}
+		return exitRuntimeError
+	}
+	return exitOK
 }
 
 func REPL(debug bool) {
@@ -5,8 +5,12 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/elliotthill/golox/interpreter"
+	"github.com/elliotthill/golox/lexer"
+	"github.com/elliotthill/golox/parser"
+	"github.com/elliotthill/golox/resolver"
 )
 
 type OutAssertTest struct {
@@ -24,6 +28,160 @@ var (
         {name: "Equality String", syntax:"print 'hello'=='hello';", expectedOut: "true", expectedErr: ""},
         {name: "Compare", syntax:"print 1<2;", expectedOut: "true", expectedErr: ""},
         {name: "OOO", syntax:"print 2*(1+1+(2*10));", expectedOut: "44", expectedErr: ""},
+        {name: "Escapes", syntax:"print 'it\\'s\\x21';", expectedOut: "it's!", expectedErr: ""},
+        {name: "Left associative", syntax:"print 100 / 10 / 5 - 1 - 1;", expectedOut: "0", expectedErr: ""},
+        {name: "Comparison operand", syntax:"print 1 < 2 + 3 and 3 * 2 * 2 == 12;", expectedOut: "true", expectedErr: ""},
+        {name: "Not", syntax:"print !nil == true;", expectedOut: "true", expectedErr: ""},
+        {name: "Ternary", syntax:"print 1 > 2 ? 'a' : 2 > 1 ? 'b' : 'c';", expectedOut: "b", expectedErr: ""},
+        {name: "Ternary short circuit", syntax:"fun boom() { print 'boom'; } print true ? 1 : boom();", expectedOut: "1", expectedErr: ""},
+        {name: "Break", syntax:"var i = 0; while (true) { i = i + 1; if (i > 3) break; print i; }", expectedOut: "123", expectedErr: ""},
+        {name: "Continue in for", syntax:"for (var i = 0; i < 5; i = i + 1) { if (i == 2) continue; print i; }", expectedOut: "0134", expectedErr: ""},
+        {name: "Break inner loop", syntax:"for (var i = 0; i < 2; i = i + 1) { for (var j = 0; j < 5; j = j + 1) { if (j == 1) break; print j; } print i; }",
+            expectedOut: "0001", expectedErr: ""},
+        {name: "Labelled break", syntax:"outer: for (var i = 0; i < 3; i = i + 1) { for (var j = 0; j < 3; j = j + 1) { if (i * j == 2) break outer; print '${i}${j}'; } }",
+            expectedOut: "0001021011", expectedErr: ""},
+        {name: "Labelled continue", syntax:"outer: for (var i = 0; i < 3; i = i + 1) { var j = 0; while (true) { j = j + 1; if (j > 1) continue outer; print i; } }",
+            expectedOut: "012", expectedErr: ""},
+        {name: "Do while", syntax:"var i = 5; do { print i; i = i + 1; } while (i < 3);", expectedOut: "5", expectedErr: ""},
+        {name: "Do while continue", syntax:"var i = 0; do { i = i + 1; if (i == 2) continue; print i; } while (i < 4);", expectedOut: "134", expectedErr: ""},
+        {name: "Class", syntax:"class Point { init(x, y) { this.x = x; this.y = y; } sum() { return this.x + this.y; } } var p = Point(2, 3); print p.sum(); print p;",
+            expectedOut: "5Pointinstance", expectedErr: ""},
+        {name: "Fields", syntax:"class Box {} var b = Box(); b.value = 4; b.value = b.value * 2; print b.value; print Box;", expectedOut: "8Box", expectedErr: ""},
+        {name: "Bound method", syntax:"class Counter { init() { this.n = 0; } inc() { this.n = this.n + 1; return this.n; } } var c = Counter(); var inc = c.inc; inc(); print inc();",
+            expectedOut: "2", expectedErr: ""},
+        {name: "This in closure", syntax:"class Greeter { init(name) { this.name = name; } greeter() { fun greet() { print 'hi ${this.name}'; } return greet; } } Greeter('bob').greeter()();",
+            expectedOut: "hibob", expectedErr: ""},
+        {name: "Init returns instance", syntax:"class A { init() { this.v = 1; return; } } var a = A(); print a.init() == a;", expectedOut: "true", expectedErr: ""},
+        {name: "Instance identity", syntax:"class A {} print A() == A();", expectedOut: "false", expectedErr: ""},
+        {name: "Inheritance", syntax:"class A { hi() { return 'A'; } name() { return 'a'; } } class B < A { hi() { return 'B${super.hi()}'; } } class C < B { hi() { return 'C'; } } print '${B().hi()}${C().name()}';",
+            expectedOut: "BAa", expectedErr: ""},
+        {name: "Super binds this", syntax:"class A { init(v) { this.v = v; } get() { return this.v; } } class B < A { init() { super.init(7); } get() { return super.get() * 2; } } print B().get();",
+            expectedOut: "14", expectedErr: ""},
+        {name: "Inherit init", syntax:"class A { init(v) { this.v = v; } } class B < A {} print B(3).v;", expectedOut: "3", expectedErr: ""},
+        {name: "Inherit from itself", syntax:"class A < A {}", expectedOut: "",
+            expectedErr: "[line1:11]Aclasscan'tinheritfromitself.FoundIDENTIFIER'A'."},
+        {name: "Super without superclass", syntax:"class A { f() { super.f(); } }", expectedOut: "",
+            expectedErr: "[line1:17]Can'tuse'super'inaclasswithnosuperclass.FoundSUPER'super'."},
+        {name: "This outside class", syntax:"print this;", expectedOut: "",
+            expectedErr: "[line1:7]Can'tuse'this'outsideofaclass.FoundTHIS'this'."},
+        {name: "Break outside loop", syntax:"break;", expectedOut: "",
+            expectedErr: "[line1:1]Can'tuse'break'outsideofaloop.FoundBREAK'break'."},
+        {name: "Closure binding", syntax:"var a = 'global'; { fun showA() { print a; } showA(); var a = 'block'; showA(); print a; }",
+            expectedOut: "globalglobalblock", expectedErr: ""},
+        {name: "Nil variable", syntax:"var n; print n;", expectedOut: "nil", expectedErr: ""},
+        {name: "Forward global", syntax:"fun first() { return later; } var later = 'ok'; print first();", expectedOut: "ok", expectedErr: ""},
+        {name: "Own initializer", syntax:"{ var a = 1; { var a = a + 1; } }", expectedOut: "",
+            expectedErr: "[line1:24]Can'treadlocalvariableinitsowninitializer."},
+        {name: "Top level return", syntax:"return 1;", expectedOut: "",
+            expectedErr: "[line1:1]Can'treturnfromtop-levelcode."},
+        {name: "Duplicate local", syntax:"fun f(a) { var a = 1; var b; var b; }", expectedOut: "",
+            expectedErr: "[line1:16]Alreadyavariablenamed'a'inthisscope.[line1:34]Alreadyavariablenamed'b'inthisscope."},
+        {name: "Misspelled variable", syntax:"fun f() { var count = 1; return cuont; }", expectedOut: "",
+            expectedErr: "[line1:33]Undefinedvariable'cuont'."},
+        {name: "Return value from init", syntax:"class A { init() { return 1; } }", expectedOut: "",
+            expectedErr: "[line1:20]Can'treturnavaluefromaninitializer."},
+        {name: "List literal", syntax:"var xs = [1, 'two', [3], nil,]; print xs; print len(xs);", expectedOut: "[1,two,[3],nil]4", expectedErr: ""},
+        {name: "List index", syntax:"var xs = [10, 20, 30]; print xs[0] + xs[-1]; xs[1] = xs[1] * 2; xs[-3] = 0; print xs;", expectedOut: "40[0,40,30]", expectedErr: ""},
+        {name: "Nested index", syntax:"var grid = [[1, 2], [3, 4]]; grid[1][0] = 5; print grid[1][0] + grid[0][1];", expectedOut: "7", expectedErr: ""},
+        {name: "List built-ins", syntax:"var xs = []; push(xs, 1); print push(xs, 3); insert(xs, 1, 2); insert(xs, 3, 4); print xs; print pop(xs); print slice(xs, 1, 3); print slice(xs, -2, 3); print len('größe');",
+            expectedOut: "2[1,2,3,4]4[2,3][2,3]5", expectedErr: ""},
+        {name: "List shared", syntax:"var a = [1]; var b = a; push(b, 2); print a;", expectedOut: "[1,2]", expectedErr: ""},
+        {name: "Map literal", syntax:"var m = {'b': 2, 'a': 1, 10: 'ten', 2: 'two',}; print m; print len(m); print {};", expectedOut: "{2:two,10:ten,a:1,b:2}4{}", expectedErr: ""},
+        {name: "Map index", syntax:"var m = {'n': 1}; m['n'] = m['n'] + 1; m[3] = 'x'; print m['n']; print m[3];", expectedOut: "2x", expectedErr: ""},
+        {name: "Map built-ins", syntax:"var m = {'a': 1, 'b': 2}; print has(m, 'a'); print delete(m, 'a'); print delete(m, 'a'); print has(m, 'a'); print keys(m); print values(m);",
+            expectedOut: "truetruefalsefalse[b][2]", expectedErr: ""},
+        {name: "Map iteration", syntax:"var m = {'x': 1, 'y': 2}; var ks = keys(m); for (var i = 0; i < len(ks); i = i + 1) { print '${ks[i]}=${m[ks[i]]}'; }", expectedOut: "x=1y=2", expectedErr: ""},
+        {name: "Block is not a map", syntax:"{ print 1; }", expectedOut: "1", expectedErr: ""},
+        {name: "Destructure tuple", syntax:"fun pair(a) { return [a, a * 2]; } var (a, b) = pair(3); print a + b;", expectedOut: "9", expectedErr: ""},
+        {name: "Destructure rest", syntax:"{ var [x, y, ...rest] = [1, 2, 3, 4]; print x; print y; print rest; var [...all] = []; print all; }", expectedOut: "12[3,4][]", expectedErr: ""},
+        {name: "Destructure names", syntax:"var {name, age} = {'name': 'ada', 'age': 36}; print '${name} ${age}';", expectedOut: "ada36", expectedErr: ""},
+        {name: "Destructure instance", syntax:"class P { init() { this.x = 1; this.y = 2; } } var {x, y} = P(); print x + y;", expectedOut: "3", expectedErr: ""},
+        {name: "Parallel assignment", syntax:"var a = 1; var b = 2; a, b = b, a; print a; print b; var xs = [0, 0]; xs[0], xs[1] = a + 1, b + 1; print xs;", expectedOut: "21[3,2]", expectedErr: ""},
+        {name: "Parallel assignment count", syntax:"var a; var b; a, b = 1;", expectedOut: "", expectedErr: "[line1:20]Assignmenthas2targetsbut1values.FoundEQUAL'='."},
+        {name: "Rest in name pattern", syntax:"var {a, ...b} = {};", expectedOut: "", expectedErr: "[line1:12]Can'tuse'...'whendestructuringbyname.FoundIDENTIFIER'b'."},
+        {name: "Default parameters", syntax:"fun greet(name, greeting = 'hi') { print '${greeting} ${name}'; } greet('ada'); greet('bob', 'yo');", expectedOut: "hiadayobob", expectedErr: ""},
+        {name: "Default at call time", syntax:"var n = 1; fun f(x = n * 10) { return x; } print f(); n = 2; print f(); print f(5);", expectedOut: "10205", expectedErr: ""},
+        {name: "Default in closure", syntax:"fun make(n) { return fun (x = n) { return x; }; } var f = make(7); print f();", expectedOut: "7", expectedErr: ""},
+        {name: "Rest parameter", syntax:"fun f(a, b = 2, ...extra) { print a + b; print extra; } f(1); f(1, 1, 3, 4);", expectedOut: "3[]2[3,4]", expectedErr: ""},
+        {name: "Method defaults", syntax:"class C { init(x = 1) { this.x = x; } get(d = this.x) { return d; } } print C().get(); print C(3).get(4);", expectedOut: "14", expectedErr: ""},
+        {name: "Default order", syntax:"fun f(a = 1, b) {}", expectedOut: "",
+            expectedErr: "[line1:14]Parameterwithoutadefaultcan'tfollowonewithadefault.FoundIDENTIFIER'b'."},
+        {name: "Named arguments", syntax:"fun render(doc, pretty = false, depth = 1) { print '${doc} ${pretty} ${depth}'; } render('a', depth: 3); render('b', depth: 2, pretty: true); render(doc: 'c');",
+            expectedOut: "afalse3btrue2cfalse1", expectedErr: ""},
+        {name: "Named initializer", syntax:"class P { init(x = 0, y = 0) { this.y = y; } } print P(y: 5).y;", expectedOut: "5", expectedErr: ""},
+        {name: "Positional after named", syntax:"fun f(a, b) {} f(a: 1, 2);", expectedOut: "",
+            expectedErr: "[line1:24]Positionalargumentcan'tfollownamedarguments.FoundNUMBER'2'."},
+        {name: "Duplicate named", syntax:"fun f(a) {} f(a: 1, a: 2);", expectedOut: "",
+            expectedErr: "[line1:21]Duplicatenamedargument'a'.FoundIDENTIFIER'a'."},
+        {name: "Match literals", syntax:"fun f(v) { match (v) { case 1, 2 => print 'small'; case -1 => print 'neg'; case 'a' => print 'str'; case nil => print 'nil'; case _ => print 'other'; } } f(2); f(-1); f('a'); f(nil); f(true);",
+            expectedOut: "smallnegstrnilother", expectedErr: ""},
+        {name: "Match lists", syntax:"fun f(v) { match (v) { case [] => print 'empty'; case [x, y] => print x + y; case [head, ...tail] => print tail; } } f([]); f([1, 2]); f([1, 2, 3]); f('x');",
+            expectedOut: "empty3[2,3]", expectedErr: "Warning:[line1:12]Somevaluesmatchnocase,add'case_'tohandlethem."},
+        {name: "Match maps", syntax:"fun f(v) { match (v) { case {kind: 'a', size} => print size; case {'kind': 'b', 1: [n]} => print n; } } f({'kind': 'a', 'size': 4}); f({'kind': 'b', 1: [7]}); f({'kind': 'c'});",
+            expectedOut: "47", expectedErr: "Warning:[line1:12]Somevaluesmatchnocase,add'case_'tohandlethem."},
+        {name: "Map name keys", syntax:"var kind = 'b'; var m = {kind: 1, (kind): 2}; print m['kind']; print m['b']; match (m) { case {kind: k} => print k; case _ => print 0; }",
+            expectedOut: "121", expectedErr: ""},
+        {name: "Match guards", syntax:"var x = 5; match (x) { case n if n > 10 => print 'big'; case _ if x > 3 => { print 'medium'; } case _ => print 'small'; }",
+            expectedOut: "medium", expectedErr: ""},
+        {name: "Match unreachable case", syntax:"match (1) {\ncase v => print v;\ncase 2 => print 2;\n}",
+            expectedOut: "1", expectedErr: "Warning:[line3:1]Novaluecanreachthiscase,thecaseonline2matcheseverything."},
+        {name: "Match without catch-all", syntax:"match (5) { case 1 => print 1; }\nprint 2;", expectedOut: "2",
+            expectedErr: "Warning:[line1:1]Somevaluesmatchnocase,add'case_'tohandlethem."},
+        {name: "Match alternatives bind", syntax:"match (1) { case [a], a => print a; }", expectedOut: "",
+            expectedErr: "[line1:19]Can'tbindnamesinacasewithseveralpatterns.[line1:23]Can'tbindnamesinacasewithseveralpatterns."},
+        {name: "Match bad pattern", syntax:"match (1) { case 1 + 2 => print 3; }", expectedOut: "",
+            expectedErr: "[line1:20]Expect'=>'aftercasepattern.ExpectedARROW,foundPLUS'+'.[line1:36]Expectexpression.FoundRIGHT_BRACE'}'."},
+        {name: "Catch thrown value", syntax:"try { print 1; throw 'oops'; print 2; } catch (e) { print e; }", expectedOut: "1oops", expectedErr: ""},
+        {name: "Catch runtime error", syntax:"fun inner() {\n return [][0];\n}\nfun outer() { return inner(); }\ntry { outer(); } catch (e) { print e.message; print e.line; print e.stack; }",
+            expectedOut: "Index0outofrangeforlistoflength0.2[<fninner>calledonline4,<fnouter>calledonline5]", expectedErr: ""},
+        {name: "Catch top-level error", syntax:"try { nil.x; } catch (e) { print e; print len(e.stack); }", expectedOut: "Error:Onlyinstanceshaveproperties.0", expectedErr: ""},
+        {name: "Error values", syntax:"try { throw Error('bad'); } catch (e) { print e.message; }", expectedOut: "bad", expectedErr: ""},
+        {name: "Finally", syntax:"try { print 1; } finally { print 2; } try { throw 1; } catch (e) { print 3; } finally { print 4; }", expectedOut: "1234", expectedErr: ""},
+        {name: "Rethrow through finally", syntax:"try { try { throw 'x'; } finally { print 'inner'; } } catch (e) { print e; }", expectedOut: "innerx", expectedErr: ""},
+        {name: "Return through finally", syntax:"fun f() { try { return 1; } finally { print 'cleanup'; } } print f();", expectedOut: "cleanup1", expectedErr: ""},
+        {name: "Break through finally", syntax:"while (true) { try { break; } finally { print 'done'; } } print 'after';", expectedOut: "doneafter", expectedErr: ""},
+        {name: "Catch in loop", syntax:"for (var i = 0; i < 3; i = i + 1) { try { if (i == 1) throw i; print i; } catch (e) { print 'caught ${e}'; } }", expectedOut: "0caught12", expectedErr: ""},
+        {name: "Try without catch", syntax:"try { print 1; }", expectedOut: "",
+            expectedErr: "[line1:17]Expect'catch'or'finally'aftertryblock.ExpectedCATCHorFINALLY,foundendoffile."},
+        {name: "Missing semicolon before brace", syntax:"fun f() { print 1 }\nprint 2;\nwhile (true) { var x = 1 }\nprint 3;", expectedOut: "",
+            expectedErr: "[line1:19]Expect';'aftervalue.ExpectedSEMICOLON,foundRIGHT_BRACE'}'.[line3:26]Expected';'aftervariabledeclaration.ExpectedSEMICOLON,foundRIGHT_BRACE'}'."},
+        {name: "Message without full stop", syntax:"fun g(1) {}", expectedOut: "",
+            expectedErr: "[line1:7]Expectparametername.ExpectedIDENTIFIER,foundNUMBER'1'."},
+        {name: "Uncaught error stack", syntax:"fun inner() {\n  return nil.x;\n}\nfun outer() { inner(); }\nouter();", expectedOut: "",
+            expectedErr: "[line2]Onlyinstanceshaveproperties.<fninner>calledonline4<fnouter>calledonline5"},
+        {name: "Uncaught throw", syntax:"print 1;\nthrow 'oops';\nprint 2;", expectedOut: "1", expectedErr: "[line2]Uncaughtexception:oops"},
+        {name: "Native equality", syntax:"var l = len; print len == len; print l == len; print len == push;", expectedOut: "truetruefalse", expectedErr: ""},
+        {name: "Empty interpolation", syntax:"print 'a${}b';", expectedOut: "", expectedErr: "[line1:11]Expectexpressioninside${}.FoundINTERPOLATION_END'}b''."},
+        {name: "Incomplete interpolation", syntax:"print 'a${1 +}b${2}c';", expectedOut: "", expectedErr: "[line1:14]Expectexpression.FoundINTERPOLATION_MIDDLE'}b${'."},
+        {name: "List containing itself", syntax:"var xs = [1]; push(xs, xs); print xs; print [xs, xs];", expectedOut: "[1,[...]][[1,[...]],[1,[...]]]", expectedErr: ""},
+        {name: "Map containing itself", syntax:"var m = {}; m['a'] = m; print m; var xs = [m]; m['b'] = xs; print '${xs}'; print values(m);",
+            expectedOut: "{a:{...}}[{a:{...},b:[...]}][{a:{...},b:[{...}]},[{a:{...},b:[...]}]]", expectedErr: ""},
+        {name: "Interpolation", syntax:"print 'total: ${1 + 2}!';", expectedOut: "total:3!", expectedErr: ""},
+        {name: "Interpolation quotes", syntax:"print \"a ${'b' == 'b'} c\";", expectedOut: "atruec", expectedErr: ""},
+        {name: "Nested interpolation", syntax:"print 'x${\"y${1}\"}z';", expectedOut: "xy1z", expectedErr: ""},
+        {name: "Interpolation braces", syntax:"print '${fun (a) { return a * 2; }(4)}';", expectedOut: "8", expectedErr: ""},
+        {name: "Escaped interpolation", syntax:"print '\\${1}';", expectedOut: "${1}", expectedErr: ""},
+        {name: "Block comment", syntax:"print /* 1 /* nested */ */ 2;", expectedOut: "2", expectedErr: ""},
+        {name: "Number literals", syntax:"print 0xFF + 0b1 + 0o10 + 1_000 + 1e2;", expectedOut: "1364", expectedErr: ""},
+        {name: "Unicode", syntax:"var größe = 'ü'; print '${größe}${größe}';", expectedOut: "üü", expectedErr: ""},
+        {name: "Raw string", syntax:"print `\n    {\"a\": '${1}'}\n    `;", expectedOut: "{\"a\":'${1}'}", expectedErr: ""},
+        {name: "Parse error", syntax:"print 1\nprint 2;", expectedOut: "",
+            expectedErr: "[line2:1]Expect';'aftervalue.ExpectedSEMICOLON,foundPRINT'print'."},
+        {name: "Multiple parse errors", syntax:"var = 1; print 2; 1 = 2; print (3;", expectedOut: "",
+            expectedErr: "[line1:5]Expectvariablename.ExpectedIDENTIFIER,foundEQUAL'='." +
+                "[line1:21]Invalidassignmenttarget.FoundEQUAL'='." +
+                "[line1:34]Expect')'afterexpression.ExpectedRIGHT_PAREN,foundSEMICOLON';'."},
+        {name: "Multiple parse errors in map", syntax:"{ var m = {\"a\" 1}; }\nprint 2\n{ var n = {\"b\": 2 3}; print 1 }", expectedOut: "",
+            expectedErr: "[line1:16]Expect':'aftermapkey.ExpectedCOLON,foundNUMBER'1'." +
+                "[line3:1]Expect';'aftervalue.ExpectedSEMICOLON,foundLEFT_BRACE'{'." +
+                "[line3:19]Expect'}'aftermapentries.ExpectedRIGHT_BRACE,foundNUMBER'3'." +
+                "[line3:31]Expect';'aftervalue.ExpectedSEMICOLON,foundRIGHT_BRACE'}'."},
+        {name: "Parse error at EOF", syntax:"print (1", expectedOut: "",
+            expectedErr: "[line1:9]Expect')'afterexpression.ExpectedRIGHT_PAREN,foundendoffile."},
+        {name: "Unexpected character", syntax:"print 1 # 2;", expectedOut: "",
+            expectedErr: "[line1:9]UNEXPECTED_CHARACTER:Unexpectedcharacter.'#'"},
+        {name: "Unterminated string", syntax:"print 'hello;", expectedOut: "",
+            expectedErr: "[line1:7]UNTERMINATED_STRING:Unterminatedstring.''hello;'"},
     }
 )
 
@@ -33,6 +191,7 @@ func TestRun(t *testing.T) {
     var errBuf bytes.Buffer = bytes.Buffer{}
 
     interp := interpreter.NewInterpreter(&outBuf, &errBuf)
+    defaultErr = &errBuf
 
     for _, test := range assertTests {
 
@@ -44,6 +203,12 @@ func TestRun(t *testing.T) {
         if (output != test.expectedOut) {
             t.Errorf("Got %s, expected %s", strconv.Quote(output), strconv.Quote(test.expectedOut))
         }
+
+        errOutput := StripAll(errBuf.String())
+
+        if (errOutput != test.expectedErr) {
+            t.Errorf("%s: got error %s, expected %s", test.name, strconv.Quote(errOutput), strconv.Quote(test.expectedErr))
+        }
         outBuf.Reset()
         errBuf.Reset()
     }
@@ -54,6 +219,92 @@ func TestRun(t *testing.T) {
 
 
 
+type RuntimeErrorTest struct {
+    syntax   string
+    expected string
+}
+
+func TestRuntimeErrors(t *testing.T) {
+
+    tests := []RuntimeErrorTest{
+        {syntax: "[1, 2][2];", expected: "[line 1] Index 2 out of range for list of length 2."},
+        {syntax: "[1, 2][-3];", expected: "[line 1] Index -3 out of range for list of length 2."},
+        {syntax: "[1, 2][0.5];", expected: "[line 1] List index must be an integer, got 0.5."},
+        {syntax: "var xs = []; xs[0] = 1;", expected: "[line 1] Index 0 out of range for list of length 0."},
+        {syntax: "pop([]);", expected: "[line 1] pop() from empty list."},
+        {syntax: "push('a', 1);", expected: "[line 1] push() expects a list, got a."},
+        {syntax: "insert([1], 3, 1);", expected: "[line 1] Index 3 out of range for list of length 1."},
+        {syntax: "slice([1, 2, 3], 2, 1);", expected: "[line 1] slice() start 2 is after end 1."},
+        {syntax: "1[0];", expected: "[line 1] Only lists and maps can be indexed."},
+        {syntax: "var m = {'a': 1}; m['b'];", expected: "[line 1] Key b not found in map."},
+        {syntax: "var m = {}; m[nil] = 1;", expected: "[line 1] Map key must be a string or number, got nil."},
+        {syntax: "var m = {}; m[0/0] = 1;", expected: "[line 1] Map key can't be NaN."},
+        {syntax: "has({}, 0/0);", expected: "[line 1] Map key can't be NaN."},
+        {syntax: "var (a, b) = [1, 2, 3];", expected: "[line 1] Expected 2 values to unpack, got 3."},
+        {syntax: "var [a, b, ...c] = [1];", expected: "[line 1] Expected at least 2 values to unpack, got 1."},
+        {syntax: "var (a, b) = 'ab';", expected: "[line 1] Only lists can be destructured by position, got ab."},
+        {syntax: "var {a} = {'b': 1};", expected: "[line 1] Key a not found in map."},
+        {syntax: "var {a} = 1;", expected: "[line 1] Only maps and instances can be destructured by name, got 1."},
+        {syntax: "fun f(a, b = 1) {} f();", expected: "[line 1] Expected 1 to 2 arguments but got 0."},
+        {syntax: "fun f(a, ...b) {} f();", expected: "[line 1] Expected at least 1 arguments but got 0."},
+        {syntax: "fun f(a) {} f(1, 2);", expected: "[line 1] Expected 1 arguments but got 2."},
+        {syntax: "fun f(a) {} f(b: 1);", expected: "[line 1] <fn f> has no parameter named 'b'."},
+        {syntax: "fun f(a) {} f(1, a: 2);", expected: "[line 1] Parameter 'a' was given more than once."},
+        {syntax: "fun f(a, b = 1, c = 2) {} f(c: 3);", expected: "[line 1] Missing argument for parameter 'a'."},
+        {syntax: "len(value: [1]);", expected: "[line 1] <native fn len> doesn't accept named arguments."},
+        {syntax: "throw 'oops';", expected: "[line 1] Uncaught exception: oops"},
+        {syntax: "fun f() { [][0]; } f();", expected: "[line 1] Index 0 out of range for list of length 0."},
+        {syntax: "try { throw Error('bad'); } catch (e) { throw e; }", expected: "[line 1] bad"},
+        {syntax: "keys([1]);", expected: "[line 1] keys() expects a map, got [1]."},
+    }
+
+    for _, test := range tests {
+
+        var outBuf bytes.Buffer = bytes.Buffer{}
+        var errBuf bytes.Buffer = bytes.Buffer{}
+
+        defaultErr = &errBuf
+        code := Run(test.syntax, interpreter.NewInterpreter(&outBuf, &errBuf), false)
+
+        //The first line locates the error, any stack follows it
+        got := strings.SplitN(errBuf.String(), "\n", 2)[0]
+        if code != exitRuntimeError || got != test.expected {
+            t.Errorf("%s: got exit %d and error %q, expected %d and %q", test.syntax, code, got, exitRuntimeError, test.expected)
+        }
+    }
+}
+
+func TestStreamingParse(t *testing.T) {
+
+    var outBuf bytes.Buffer = bytes.Buffer{}
+    var errBuf bytes.Buffer = bytes.Buffer{}
+
+    source := "fun add(a, b) { return a + b; }\nfor (var i = 0; i < 3; i = i + 1) { print add(i, 10); }"
+    scanner := lexer.NewReaderScanner(iotest.OneByteReader(strings.NewReader(source)))
+    statements, errors := parser.NewStreamParser(scanner).Parse()
+
+    if len(errors) > 0 {
+        t.Fatalf("Unexpected parse errors %v", errors)
+    }
+
+    interp := interpreter.NewInterpreter(&outBuf, &errBuf)
+    locals, resolveErrors := resolver.NewResolver(interp.Globals()).Resolve(statements)
+
+    if len(resolveErrors) > 0 {
+        t.Fatalf("Unexpected resolve errors %v", resolveErrors)
+    }
+
+    interp.SetLocals(locals)
+    interp.SetStatements(statements)
+    if err := interp.Interpret(); err != nil {
+        t.Fatalf("Unexpected runtime error %v", err)
+    }
+
+    if output := StripAll(outBuf.String()); output != "101112" {
+        t.Errorf("Got %s, expected %s", strconv.Quote(output), strconv.Quote("101112"))
+    }
+}
+
 func StripAll(str string) string {
 
     str = strings.ReplaceAll(str, " ", "")
@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/elliotthill/golox/language"
+)
+
+// A syntax error at the Found token
+type ParseError struct {
+	Message  string
+	Line     int
+	Column   int
+	Expected []TokenType // Empty when any of several constructs would do
+	Found    Token
+}
+
+func (err ParseError) Error() string {
+
+	//Older messages have no full stop of their own
+	message := strings.TrimSuffix(err.Message, ".") + "."
+
+	found := fmt.Sprintf("%s '%s'", err.Found.TokenType, err.Found.Lexeme)
+	if err.Found.TokenType == EOF {
+		found = "end of file"
+	}
+
+	if len(err.Expected) == 0 {
+		return fmt.Sprintf("[line %d:%d] %s Found %s.", err.Line, err.Column, message, found)
+	}
+
+	expected := []string{}
+	for _, tokenType := range err.Expected {
+		expected = append(expected, string(tokenType))
+	}
+
+	return fmt.Sprintf("[line %d:%d] %s Expected %s, found %s.", err.Line, err.Column, message,
+		strings.Join(expected, " or "), found)
+}
+
+func newParseError(token Token, message string, expected ...TokenType) ParseError {
+
+	return ParseError{Message: message, Line: token.Line, Column: token.Column, Expected: expected, Found: token}
+}
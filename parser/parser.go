@@ -1,7 +1,8 @@
 package parser
 
 import (
-    "fmt"
+     "fmt"
+
      . "github.com/elliotthill/golox/language"
 )
 
@@ -9,6 +10,18 @@ type Parser struct {
 	tokens     []Token
 	current    int
 	statements []AbstractStatement
+	errors     []ParseError
+	loopDepth  int      //How many loops enclose the current statement
+	labels     []string //Labels of the enclosing loops
+	classes    []bool   //Enclosing class bodies, true for those with a superclass
+	braces     int      //{ consumed and not yet closed, of any kind
+	blocks     []int    //braces just inside each enclosing block, innermost last
+	source     TokenSource //Lazily supplies tokens, nil when given a slice
+}
+
+// Supplies tokens one at a time, lexer.Scanner implements this
+type TokenSource interface {
+	NextToken() Token
 }
 
 func NewParser(tokens []Token) *Parser{
@@ -19,22 +32,47 @@ func NewParser(tokens []Token) *Parser{
     return parser
 }
 
-func (parser *Parser) Parse() []AbstractStatement {
+// Parser that pulls tokens from source only as far as it needs to look ahead
+func NewStreamParser(source TokenSource) *Parser {
 
-    defer func() {
-        if r:= recover(); r!= nil {
-            fmt.Println(fmt.Sprintf("Parse error on line %d\n%s", parser.current, r));
-        }
-    }()
+	parser := new(Parser)
+	parser.current = 0
+	parser.source = source
+	return parser
+}
+
+// Parse the whole program, statements that fail to parse are left out and
+// reported as errors
+func (parser *Parser) Parse() ([]AbstractStatement, []ParseError) {
 
 	for !parser.isAtEnd() {
-		parser.statements = append(parser.statements, parser.declaration())
+		if statement := parser.declaration(); statement != nil {
+			parser.statements = append(parser.statements, statement)
+		}
+		parser.discard()
 	}
 
-	return parser.statements
+	return parser.statements, parser.errors
 }
 
-func (parser *Parser) declaration() AbstractStatement {
+// Returns nil after a syntax error, once we have skipped to the next statement
+func (parser *Parser) declaration() (statement AbstractStatement) {
+
+    defer func() {
+        if r := recover(); r != nil {
+            err, ok := r.(ParseError)
+            if !ok {
+                panic(r)
+            }
+            parser.errors = append(parser.errors, err)
+            parser.synchronize()
+            statement = nil
+        }
+    }()
+
+    if parser.match(CLASS) {
+        return parser.classDeclaration()
+    }
 
     if parser.match(FUN) && parser.check(IDENTIFIER) {
         return parser.function("function")
@@ -49,12 +87,27 @@ func (parser *Parser) declaration() AbstractStatement {
 
 func (parser *Parser) statement() AbstractStatement {
 
+    if parser.check(IDENTIFIER) && parser.checkNext(COLON) {
+        return parser.labelledStatement()
+    }
     if parser.match(FOR) {
-        return parser.forStatement()
+        return parser.forStatement("")
     }
     if parser.match(IF) {
         return parser.ifStatement()
     }
+    if parser.match(MATCH) {
+        return parser.matchStatement()
+    }
+    if parser.match(THROW) {
+        keyword := parser.previous()
+        value := parser.expression()
+        parser.consume(SEMICOLON, "Expect ';' after thrown value.")
+        return Throw{Keyword: keyword, Value: value}
+    }
+    if parser.match(TRY) {
+        return parser.tryStatement()
+    }
     if parser.match(PRINT) {
 		return parser.printStatement()
 	}
@@ -62,7 +115,18 @@ func (parser *Parser) statement() AbstractStatement {
         return parser.returnStatement()
     }
     if parser.match(WHILE) {
-        return parser.whileStatement()
+        return parser.whileStatement("")
+    }
+    if parser.match(DO) {
+        return parser.doWhileStatement("")
+    }
+    if parser.match(BREAK) {
+        keyword, label := parser.loopJump("break")
+        return Break{Keyword: keyword, Label: label}
+    }
+    if parser.match(CONTINUE) {
+        keyword, label := parser.loopJump("continue")
+        return Continue{Keyword: keyword, Label: label}
     }
     if parser.match(LEFT_BRACE) {
         return Block{Statements: parser.block()}
@@ -71,45 +135,270 @@ func (parser *Parser) statement() AbstractStatement {
 	return parser.expressionStatement()
 }
 
+func (parser *Parser) tryStatement() AbstractStatement {
+
+    keyword := parser.previous()
+    parser.consume(LEFT_BRACE, "Expect '{' after 'try'.")
+    body := parser.block()
+
+    var name *Token = nil
+    var catch []AbstractStatement = nil
+    if parser.match(CATCH) {
+        parser.consume(LEFT_PAREN, "Expect '(' after 'catch'.")
+        error := parser.consume(IDENTIFIER, "Expect error name.")
+        name = &error
+        parser.consume(RIGHT_PAREN, "Expect ')' after error name.")
+        parser.consume(LEFT_BRACE, "Expect '{' before catch body.")
+        catch = parser.block()
+    }
+
+    var finally []AbstractStatement = nil
+    if parser.match(FINALLY) {
+        parser.consume(LEFT_BRACE, "Expect '{' after 'finally'.")
+        finally = parser.block()
+    }
+
+    if catch == nil && finally == nil {
+        parser.errors = append(parser.errors, newParseError(parser.peek(), "Expect 'catch' or 'finally' after try block.", CATCH, FINALLY))
+    }
+    return Try{Keyword: keyword, Body: body, Name: name, Catch: catch, Finally: finally}
+}
+
+func (parser *Parser) matchStatement() AbstractStatement {
+
+    keyword := parser.previous()
+    parser.consume(LEFT_PAREN, "Expect '(' after 'match'.")
+    value := parser.expression()
+    parser.consume(RIGHT_PAREN, "Expect ')' after match value.")
+    parser.consume(LEFT_BRACE, "Expect '{' before match cases.")
+
+    arms := []MatchArm{}
+    for !parser.check(RIGHT_BRACE) && !parser.isAtEnd() {
+
+        _case := parser.consume(CASE, "Expect 'case' in match body.")
+        patterns := []AbstractPattern{parser.pattern()}
+        for parser.match(COMMA) {
+            patterns = append(patterns, parser.pattern())
+        }
+
+        var guard AbstractExpression = nil
+        if parser.match(IF) {
+            guard = parser.expression()
+        }
+
+        parser.consume(ARROW, "Expect '=>' after case pattern.")
+        body := parser.statement()
+        arms = append(arms, MatchArm{Case: _case, Patterns: patterns, Guard: guard, Body: body})
+    }
+
+    parser.consume(RIGHT_BRACE, "Expect '}' after match cases.")
+    return Match{Keyword: keyword, Value: value, Arms: arms}
+}
+
+// _, name, literal, [pattern, ...rest] or {key: pattern, name}
+func (parser *Parser) pattern() AbstractPattern {
+
+    if parser.match(IDENTIFIER) {
+        name := parser.previous()
+        if name.Lexeme == "_" {
+            return WildcardPattern{Underscore: name}
+        }
+        return BindingPattern{Name: name}
+    }
+
+    if parser.match(NUMBER, STRING) {
+        return LiteralPattern{Token: parser.previous(), Value: parser.previous().Literal}
+    }
+    if parser.match(MINUS) {
+        number := parser.consume(NUMBER, "Expect number after '-' in pattern.")
+        return LiteralPattern{Token: number, Value: -number.Literal.(float64)}
+    }
+    if parser.match(TRUE) {
+        return LiteralPattern{Token: parser.previous(), Value: true}
+    }
+    if parser.match(FALSE) {
+        return LiteralPattern{Token: parser.previous(), Value: false}
+    }
+    if parser.match(NIL) {
+        return LiteralPattern{Token: parser.previous(), Value: nil}
+    }
+
+    if parser.match(LEFT_BRACKET) {
+        return parser.listPattern()
+    }
+    if parser.match(LEFT_BRACE) {
+        return parser.mapPattern()
+    }
+
+    panic(newParseError(parser.peek(), "Expect pattern."))
+}
+
+func (parser *Parser) listPattern() AbstractPattern {
+
+    bracket := parser.previous()
+    elements := []AbstractPattern{}
+    var rest *Token = nil
+
+    for !parser.check(RIGHT_BRACKET) {
+        if parser.match(ELLIPSIS) {
+            name := parser.consume(IDENTIFIER, "Expect name after '...'.")
+            rest = &name
+            break //The rest name must come last
+        }
+        elements = append(elements, parser.pattern())
+
+        if !parser.match(COMMA) {
+            break
+        }
+    }
+
+    parser.consume(RIGHT_BRACKET, "Expect ']' after list pattern.")
+    return ListPattern{Bracket: bracket, Elements: elements, Rest: rest}
+}
+
+// A bare name is shorthand for name: name
+func (parser *Parser) mapPattern() AbstractPattern {
+
+    brace := parser.previous()
+    keys := []interface{}{}
+    values := []AbstractPattern{}
+
+    for !parser.check(RIGHT_BRACE) {
+        if parser.check(IDENTIFIER) && !parser.checkNext(COLON) {
+            name := parser.advance()
+            keys = append(keys, name.Lexeme)
+            values = append(values, BindingPattern{Name: name})
+        } else {
+            if parser.match(IDENTIFIER) {
+                keys = append(keys, parser.previous().Lexeme)
+            } else if parser.match(STRING, NUMBER) {
+                keys = append(keys, parser.previous().Literal)
+            } else {
+                panic(newParseError(parser.peek(), "Expect map pattern key.", IDENTIFIER, STRING, NUMBER))
+            }
+            parser.consume(COLON, "Expect ':' after map pattern key.")
+            values = append(values, parser.pattern())
+        }
+
+        if !parser.match(COMMA) {
+            break
+        }
+    }
+
+    parser.consume(RIGHT_BRACE, "Expect '}' after map pattern.")
+    return MapPattern{Brace: brace, Keys: keys, Values: values}
+}
+
 func (parser *Parser) printStatement() AbstractStatement {
 
 	value := parser.expression()
 
-	parser.consume(SEMICOLON, "Print expected ; after value.")
+	parser.consume(SEMICOLON, "Expect ';' after value.")
 	return Print{Expression: value}
 }
 
 func (parser *Parser) expressionStatement() AbstractStatement {
 
 	expr := parser.expression()
-	parser.consume(SEMICOLON, "Expect ; after expression.")
+	if parser.check(COMMA) {
+		expr = parser.parallelAssignment(expr)
+	}
+	parser.consume(SEMICOLON, "Expect ';' after expression.")
 
 	expr_statement := Expression{Expression: expr}
 	return expr_statement
 }
 
+func (parser *Parser) classDeclaration() AbstractStatement {
+
+    name := parser.consume(IDENTIFIER, "Expect class name.")
+
+    var superclass *Variable = nil
+    if parser.match(LESS) {
+        superName := parser.consume(IDENTIFIER, "Expect superclass name.")
+        superclass = &Variable{Name: superName}
+
+        if superName.Lexeme == name.Lexeme {
+            parser.errors = append(parser.errors, newParseError(superName, "A class can't inherit from itself."))
+        }
+    }
+
+    parser.consume(LEFT_BRACE, "Expect '{' before class body.")
+
+    parser.classes = append(parser.classes, superclass != nil)
+    defer func() { parser.classes = parser.classes[:len(parser.classes)-1] }()
+
+    methods := []Function{}
+    for !parser.check(RIGHT_BRACE) && !parser.isAtEnd() {
+        methods = append(methods, parser.function("method"))
+    }
+
+    parser.consume(RIGHT_BRACE, "Expect '}' after class body.")
+    return Class{Name: name, Superclass: superclass, Methods: methods}
+}
+
 func (parser *Parser) function(kind string) Function {
 
     name := parser.consume(IDENTIFIER, "Expect " + kind + " name.")
     parser.consume(LEFT_PAREN, "Expect '(' after " + kind + " name.")
 
+    parameters, defaults, rest := parser.parameters()
+
+    parser.consume(RIGHT_PAREN, "Expect ')' after parameters.")
+    parser.consume(LEFT_BRACE, "Expect '{' before " + kind + " body.")
+    body := parser.functionBody()
+    return Function{Name:name, Params:parameters, Defaults: defaults, Rest: rest, Body:body}
+}
+
+// Parameters may have a default value and the last one may be ...rest
+func (parser *Parser) parameters() ([]Token, []AbstractExpression, *Token) {
+
     parameters := []Token{}
+    defaults := []AbstractExpression{}
+    var rest *Token = nil
+
     if !parser.check(RIGHT_PAREN) {
 
         //Keep matching params between ,
         for params := true; params; params = parser.match(COMMA) {
 
-            parameters = append(parameters, parser.consume(IDENTIFIER, "Expect parameter name"))
+            if parser.match(ELLIPSIS) {
+                name := parser.consume(IDENTIFIER, "Expect parameter name after '...'.")
+                rest = &name
+                break
+            }
+
+            name := parser.consume(IDENTIFIER, "Expect parameter name")
+            var value AbstractExpression = nil
+            if parser.match(EQUAL) {
+                value = parser.expression()
+            } else if len(defaults) > 0 && defaults[len(defaults)-1] != nil {
+                parser.errors = append(parser.errors, newParseError(name, "Parameter without a default can't follow one with a default."))
+            }
+
+            parameters = append(parameters, name)
+            defaults = append(defaults, value)
         }
     }
 
-    parser.consume(RIGHT_PAREN, "Expect ')' after parameters.")
-    parser.consume(LEFT_BRACE, "Expect '{' before " + kind + " body.")
-    body := parser.block()
-    return Function{Name:name, Params:parameters, Body:body}
+    return parameters, defaults, rest
+}
+
+// Loops around a function don't reach into its body
+func (parser *Parser) functionBody() []AbstractStatement {
+
+    enclosingLoops, enclosingLabels := parser.loopDepth, parser.labels
+    parser.loopDepth, parser.labels = 0, nil
+    defer func() { parser.loopDepth, parser.labels = enclosingLoops, enclosingLabels }()
+
+    return parser.block()
 }
 
 func (parser *Parser) varDeclaration() AbstractStatement{
+    if parser.match(LEFT_PAREN, LEFT_BRACKET, LEFT_BRACE) {
+        return parser.destructuringDeclaration()
+    }
+
     name := parser.consume(IDENTIFIER, "Expect variable name.")
 
     var initializer AbstractExpression = nil
@@ -117,17 +406,57 @@ func (parser *Parser) varDeclaration() AbstractStatement{
         initializer = parser.expression()
     }
     parser.consume(SEMICOLON, "Expected ';' after variable declaration")
-
-    fmt.Println(fmt.Sprintf("%s %s", name.TokenType, initializer))
     return Var{Name:name, Initializer: initializer}
 }
 
+// var (a, b) = ..., var [a, ...rest] = ... or var {a, b} = ...
+func (parser *Parser) destructuringDeclaration() AbstractStatement {
+
+    pattern := parser.previous()
+    closing, lexeme := RIGHT_PAREN, ")"
+    if pattern.TokenType == LEFT_BRACKET {
+        closing, lexeme = RIGHT_BRACKET, "]"
+    } else if pattern.TokenType == LEFT_BRACE {
+        closing, lexeme = RIGHT_BRACE, "}"
+    }
+
+    names := []Token{}
+    var rest *Token = nil
+    for {
+        if parser.match(ELLIPSIS) {
+            name := parser.consume(IDENTIFIER, "Expect variable name after '...'.")
+            if pattern.TokenType == LEFT_BRACE {
+                parser.errors = append(parser.errors, newParseError(name, "Can't use '...' when destructuring by name."))
+            }
+            rest = &name
+            break //The rest variable must come last
+        }
+        names = append(names, parser.consume(IDENTIFIER, "Expect variable name."))
+
+        if !parser.match(COMMA) {
+            break
+        }
+    }
+
+    parser.consume(closing, "Expect '"+lexeme+"' after variable names.")
+    parser.consume(EQUAL, "Expect '=' after destructuring pattern.")
+    initializer := parser.expression()
+    parser.consume(SEMICOLON, "Expected ';' after variable declaration")
+
+    return Destructure{Pattern: pattern, Names: names, Rest: rest, Initializer: initializer}
+}
+
 func (parser *Parser) block() []AbstractStatement {
 
     statements := []AbstractStatement{}
 
+    parser.blocks = append(parser.blocks, parser.braces)
+    defer func() { parser.blocks = parser.blocks[:len(parser.blocks)-1] }()
+
     for !parser.check(RIGHT_BRACE) && !parser.isAtEnd(){
-        statements = append(statements, parser.declaration())
+        if statement := parser.declaration(); statement != nil {
+            statements = append(statements, statement)
+        }
     }
     parser.consume(RIGHT_BRACE, "Expect '}' after block.")
     return statements
@@ -149,7 +478,35 @@ func (parser *Parser) ifStatement() AbstractStatement {
     return If{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
 }
 
-func (parser *Parser) forStatement() AbstractStatement {
+// label: followed by a loop that break and continue can name
+func (parser *Parser) labelledStatement() AbstractStatement {
+
+    label := parser.advance()
+    parser.advance() //The colon
+
+    for _, enclosing := range parser.labels {
+        if enclosing == label.Lexeme {
+            parser.errors = append(parser.errors, newParseError(label, "Duplicate label '"+label.Lexeme+"'."))
+        }
+    }
+
+    parser.labels = append(parser.labels, label.Lexeme)
+    defer func() { parser.labels = parser.labels[:len(parser.labels)-1] }()
+
+    if parser.match(FOR) {
+        return parser.forStatement(label.Lexeme)
+    }
+    if parser.match(WHILE) {
+        return parser.whileStatement(label.Lexeme)
+    }
+    if parser.match(DO) {
+        return parser.doWhileStatement(label.Lexeme)
+    }
+
+    panic(newParseError(parser.peek(), "Expect loop after label.", FOR, WHILE, DO))
+}
+
+func (parser *Parser) forStatement(label string) AbstractStatement {
 
     parser.consume(LEFT_PAREN, "Expect '(' after 'for'")
 
@@ -167,9 +524,7 @@ func (parser *Parser) forStatement() AbstractStatement {
     var condition AbstractExpression = nil
 
     if !parser.check(SEMICOLON) {
-        fmt.Println("Checking for condition")
         condition = parser.expression()
-        fmt.Println(condition)
     }
     parser.consume(SEMICOLON, "Expect ';' after loop condition")
 
@@ -181,21 +536,14 @@ func (parser *Parser) forStatement() AbstractStatement {
     parser.consume(RIGHT_PAREN, "Expect ')' after for clauses.")
 
     //body
-    body := parser.statement()
-
-    //Increment and evaluate
-    if increment != nil {
-        body_statements := []AbstractStatement{}
-        body_statements = append(body_statements, body)
-        body_statements = append(body_statements, Expression{Expression: increment})
-        body = Block{Statements: body_statements}
-    }
+    body := parser.loopBody()
 
     if condition == nil {
         condition = Literal{Value: true}
     }
 
-    body = While{Condition: condition, Body: body}
+    //The increment lives on the loop so continue still runs it
+    body = While{Condition: condition, Body: body, Increment: increment, Label: label}
 
     if initializer != nil {
         body_statements := []AbstractStatement{}
@@ -208,15 +556,69 @@ func (parser *Parser) forStatement() AbstractStatement {
     return body
 }
 
-func (parser *Parser) whileStatement() AbstractStatement {
+func (parser *Parser) whileStatement(label string) AbstractStatement {
 
     parser.consume(LEFT_PAREN, "Expect ')' after 'while' ")
     condition := parser.expression()
     parser.consume(RIGHT_PAREN, "Expected ')' after condition")
 
-    body := parser.statement()
+    body := parser.loopBody()
 
-    return While{Condition: condition, Body: body}
+    return While{Condition: condition, Body: body, Label: label}
+}
+
+func (parser *Parser) doWhileStatement(label string) AbstractStatement {
+
+    body := parser.loopBody()
+
+    parser.consume(WHILE, "Expect 'while' after do body.")
+    parser.consume(LEFT_PAREN, "Expect '(' after 'while'.")
+    condition := parser.expression()
+    parser.consume(RIGHT_PAREN, "Expect ')' after condition.")
+    parser.consume(SEMICOLON, "Expect ';' after do while condition.")
+
+    return DoWhile{Body: body, Condition: condition, Label: label}
+}
+
+func (parser *Parser) loopBody() AbstractStatement {
+
+    parser.loopDepth++
+    defer func() { parser.loopDepth-- }()
+
+    return parser.statement()
+}
+
+// Parse the rest of a break or continue, returning its keyword and label
+func (parser *Parser) loopJump(kind string) (Token, *Token) {
+
+    keyword := parser.previous()
+
+    if parser.loopDepth == 0 {
+        parser.errors = append(parser.errors, newParseError(keyword, "Can't use '"+kind+"' outside of a loop."))
+    }
+
+    var label *Token = nil
+    if parser.match(IDENTIFIER) {
+        name := parser.previous()
+        label = &name
+
+        if !parser.hasLabel(name.Lexeme) {
+            parser.errors = append(parser.errors, newParseError(name, "Undefined label '"+name.Lexeme+"'."))
+        }
+    }
+
+    parser.consume(SEMICOLON, "Expect ';' after '"+kind+"'.")
+    return keyword, label
+}
+
+func (parser *Parser) hasLabel(name string) bool {
+
+    for _, label := range parser.labels {
+        if label == name {
+            return true
+        }
+    }
+    return false
 }
 
 func (parser *Parser) returnStatement() AbstractStatement {
@@ -244,103 +646,98 @@ func (parser *Parser) expression() AbstractExpression {
 
 func (parser *Parser) assignment() AbstractExpression {
 
-	expr := parser.or()
+	expr := parser.conditional()
 
 	if parser.match(EQUAL) {
         equals := parser.previous()
 		value := parser.assignment()
 
-		variable, ok := expr.(Variable)
-		if ok {
+		if variable, ok := expr.(*Variable); ok {
+
+			return &Assign{Name: variable.Name, Value: value}
+		} else if get, ok := expr.(Get); ok {
+
+			return Set{Object: get.Object, Name: get.Name, Value: value}
+		} else if index, ok := expr.(Index); ok {
 
-			return Assign{Name: variable.Name, Value: value}
+			return IndexSet{Object: index.Object, Bracket: index.Bracket, Index: index.Index, Value: value}
 		} else {
-			panic(equals.TokenType + "Invalid assignment target")
+			//Report without panicking, the parser isn't confused
+			parser.errors = append(parser.errors, newParseError(equals, "Invalid assignment target."))
 		}
 	}
 
 	return expr
 }
 
-func (parser *Parser) or() AbstractExpression {
+// a, b = b, a, only allowed as a statement so commas in arguments stay unambiguous
+func (parser *Parser) parallelAssignment(first AbstractExpression) AbstractExpression {
 
-	expr := parser.and()
-
-	for parser.match(OR) {
-		operator := parser.previous()
-		right := parser.and()
-		expr = Logical{Left: expr, Operator: operator, Right: right}
+	targets := []AbstractExpression{first}
+	for parser.match(COMMA) {
+		targets = append(targets, parser.conditional())
 	}
-	return expr
-}
+	equals := parser.consume(EQUAL, "Expect '=' after assignment targets.")
 
-func (parser *Parser) and() AbstractExpression {
-
-	expr := parser.equality()
-
-	for parser.match(AND) {
-
-		operator := parser.previous()
-		right := parser.equality()
-		expr := Logical{Left: expr, Operator: operator, Right: right}
-		return expr
+	values := []AbstractExpression{parser.conditional()}
+	for parser.match(COMMA) {
+		values = append(values, parser.conditional())
 	}
 
-	return expr
-}
-
-func (parser *Parser) equality() AbstractExpression {
-
-	expr := parser.comparison()
-
-	for parser.match(BANG_EQUAL, EQUAL_EQUAL) {
-		operator := parser.previous()
-		right := parser.comparison()
-		expr := Binary{Left: expr, Operator: operator, Right: right}
-		return expr
+	for _, target := range targets {
+		switch target.(type) {
+		case *Variable, Get, Index:
+		default:
+			parser.errors = append(parser.errors, newParseError(equals, "Invalid assignment target."))
+		}
 	}
-	return expr
+	if len(targets) != len(values) {
+		parser.errors = append(parser.errors, newParseError(equals,
+			fmt.Sprintf("Assignment has %d targets but %d values.", len(targets), len(values))))
+	}
+
+	return ParallelAssign{Equals: equals, Targets: targets, Values: values}
 }
 
-func (parser *Parser) comparison() AbstractExpression {
+// cond ? a : b binds looser than or and nests to the right
+func (parser *Parser) conditional() AbstractExpression {
 
-	expr := parser.term()
+	expr := parser.binary(PREC_OR)
 
-	for parser.match(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL) {
+	if parser.match(QUESTION) {
+		question := parser.previous()
+		middle := parser.expression()
+		colon := parser.consume(COLON, "Expect ':' after then branch of conditional expression.")
+		right := parser.conditional()
 
-		operator := parser.previous()
-		right := parser.primary()
-		expr := Binary{Left: expr, Operator: operator, Right: right}
-		return expr
+		return Ternary{Left: expr, LeftOperator: question, Middle: middle, RightOperator: colon, Right: right}
 	}
 
 	return expr
 }
 
-func (parser *Parser) term() AbstractExpression {
+// Binary operators climb the precedence table, see precedence.go
+func (parser *Parser) binary(minimum Precedence) AbstractExpression {
 
-	expr := parser.factor()
-	for parser.match(MINUS, PLUS) {
-		operator := parser.previous()
-		right := parser.factor()
-		expr = Binary{Left: expr, Operator: operator, Right: right}
-	}
+	expr := parser.unary()
 
-	return expr
-}
+	for {
+		operator, ok := binaryOperators[parser.peek().TokenType]
+		if !ok || operator.precedence < minimum {
+			return expr
+		}
 
-func (parser *Parser) factor() AbstractExpression {
+		token := parser.advance()
 
-	expr := parser.unary()
+		//Left associative operators only take tighter operators on their right
+		next := operator.precedence + 1
+		if operator.rightAssociative {
+			next = operator.precedence
+		}
 
-	for parser.match(SLASH, STAR) {
-		operator := parser.previous()
-		right := parser.unary()
-		expr := Binary{Left: expr, Operator: operator, Right: right}
-		return expr
+		right := parser.binary(next)
+		expr = operator.build(expr, token, right)
 	}
-
-	return expr
 }
 
 func (parser *Parser) unary() AbstractExpression {
@@ -358,47 +755,67 @@ func (parser *Parser) call() AbstractExpression {
 
     expr := parser.functionExpression()
 
-    for parser.match(LEFT_PAREN) {
-        expr = parser.finishCall(expr)
+    for {
+        if parser.match(LEFT_PAREN) {
+            expr = parser.finishCall(expr)
+        } else if parser.match(DOT) {
+            name := parser.consume(IDENTIFIER, "Expect property name after '.'.")
+            expr = Get{Object: expr, Name: name}
+        } else if parser.match(LEFT_BRACKET) {
+            bracket := parser.previous()
+            index := parser.expression()
+            parser.consume(RIGHT_BRACKET, "Expect ']' after index.")
+            expr = Index{Object: expr, Bracket: bracket, Index: index}
+        } else {
+            return expr
+        }
     }
-
-    return expr
 }
 
 func (parser *Parser) finishCall(callee AbstractExpression) AbstractExpression {
 
     arguments := []AbstractExpression{}
+    named := []NamedArgument{}
 
     if !parser.check(RIGHT_PAREN) {
 
         for match_comma := true; match_comma; match_comma = parser.match(COMMA) {
 
+            if parser.check(IDENTIFIER) && parser.checkNext(COLON) {
+                name := parser.advance()
+                parser.advance()
+                for _, argument := range named {
+                    if argument.Name.Lexeme == name.Lexeme {
+                        parser.errors = append(parser.errors, newParseError(name, "Duplicate named argument '"+name.Lexeme+"'."))
+                    }
+                }
+                named = append(named, NamedArgument{Name: name, Value: parser.expression()})
+                continue
+            }
+
+            if len(named) > 0 {
+                parser.errors = append(parser.errors, newParseError(parser.peek(), "Positional argument can't follow named arguments."))
+            }
             arguments = append(arguments, parser.expression())
         }
 
     }
     paren := parser.consume(RIGHT_PAREN, "Expect ')' after arguments")
 
-    return Call{Callee: callee, Paren: paren, Arguments: arguments }
+    return Call{Callee: callee, Paren: paren, Arguments: arguments, Named: named }
 }
 
 func (parser *Parser) functionExpression() AbstractExpression {
 
     if parser.match(FUN) {
 
-        fmt.Println("We got here")
-        parameters := []Token{}
         parser.consume(LEFT_PAREN, "Expect '(' after fun keyword")
-
-        for params := true; params; params = parser.match(COMMA) {
-            parameters = append(parameters,
-                parser.consume(IDENTIFIER, "Expect parameter name"))
-        }
+        parameters, defaults, rest := parser.parameters()
 
         parser.consume(RIGHT_PAREN, "Expect ')' after parameters")
         parser.consume(LEFT_BRACE, "Expect '{' before function body")
-        body := parser.block()
-        return FunctionExpression{Params: parameters, Body: body}
+        body := parser.functionBody()
+        return FunctionExpression{Params: parameters, Defaults: defaults, Rest: rest, Body: body}
     }
 
     return parser.primary()
@@ -423,17 +840,126 @@ func (parser *Parser) primary() AbstractExpression {
 		return expr
 	}
 
+	if parser.match(INTERPOLATION) {
+		return parser.interpolation()
+	}
+
+	if parser.match(SUPER) {
+		keyword := parser.previous()
+		parser.consume(DOT, "Expect '.' after 'super'.")
+		method := parser.consume(IDENTIFIER, "Expect superclass method name.")
+
+		if len(parser.classes) == 0 {
+			parser.errors = append(parser.errors, newParseError(keyword, "Can't use 'super' outside of a class."))
+		} else if !parser.classes[len(parser.classes)-1] {
+			parser.errors = append(parser.errors, newParseError(keyword, "Can't use 'super' in a class with no superclass."))
+		}
+		return &Super{Keyword: keyword, Method: method}
+	}
+
+	if parser.match(THIS) {
+		keyword := parser.previous()
+		if len(parser.classes) == 0 {
+			parser.errors = append(parser.errors, newParseError(keyword, "Can't use 'this' outside of a class."))
+		}
+		return &This{Keyword: keyword}
+	}
+
 	if parser.match(IDENTIFIER) {
-		return Variable{Name: parser.previous()}
+		return &Variable{Name: parser.previous()}
+	}
+
+	if parser.match(LEFT_BRACKET) {
+		return parser.list()
+	}
+
+	//A { starting a statement is a block, anywhere else it opens a map
+	if parser.match(LEFT_BRACE) {
+		return parser.mapLiteral()
 	}
 
 	if parser.match(LEFT_PAREN) {
 		expr := parser.expression()
-		parser.consume(RIGHT_PAREN, "Expect ')' after expression. ")
+		parser.consume(RIGHT_PAREN, "Expect ')' after expression.")
 		return Grouping{Expression: expr}
 	}
 
-	panic("Expected expression")
+	panic(newParseError(parser.peek(), "Expect expression."))
+}
+
+// List literal, a trailing comma is allowed
+func (parser *Parser) list() AbstractExpression {
+
+	bracket := parser.previous()
+	elements := []AbstractExpression{}
+
+	for !parser.check(RIGHT_BRACKET) {
+		elements = append(elements, parser.expression())
+
+		if !parser.match(COMMA) {
+			break
+		}
+	}
+
+	parser.consume(RIGHT_BRACKET, "Expect ']' after list elements.")
+	return List{Bracket: bracket, Elements: elements}
+}
+
+// Map literal, a trailing comma is allowed
+func (parser *Parser) mapLiteral() AbstractExpression {
+
+	brace := parser.previous()
+	keys := []AbstractExpression{}
+	values := []AbstractExpression{}
+
+	for !parser.check(RIGHT_BRACE) {
+		//As in map patterns a bare name is a string key, (name): looks the variable up
+		if parser.check(IDENTIFIER) && parser.checkNext(COLON) {
+			keys = append(keys, Literal{Value: parser.advance().Lexeme})
+		} else {
+			keys = append(keys, parser.expression())
+		}
+		parser.consume(COLON, "Expect ':' after map key.")
+		values = append(values, parser.expression())
+
+		if !parser.match(COMMA) {
+			break
+		}
+	}
+
+	parser.consume(RIGHT_BRACE, "Expect '}' after map entries.")
+	return Map{Brace: brace, Keys: keys, Values: values}
+}
+
+// Each INTERPOLATION or INTERPOLATION_MIDDLE token is followed by an expression,
+// the final part of the string arrives as an INTERPOLATION_END token
+func (parser *Parser) interpolation() AbstractExpression {
+
+	parts := []AbstractExpression{}
+
+	for {
+		if text := parser.previous().Literal; text != "" {
+			parts = append(parts, Literal{Value: text})
+		}
+
+		//The string resumes straight after the } of an empty ${}
+		if parser.check(INTERPOLATION_MIDDLE) || parser.check(INTERPOLATION_END) {
+			parser.errors = append(parser.errors, newParseError(parser.peek(), "Expect expression inside ${}."))
+		} else {
+			parts = append(parts, parser.expression())
+		}
+
+		if !parser.match(INTERPOLATION_MIDDLE) {
+			break
+		}
+	}
+
+	tail := parser.consume(INTERPOLATION_END, "Expect end of string after interpolation.")
+	if tail.Literal != "" {
+		parts = append(parts, Literal{Value: tail.Literal})
+	}
+
+	return Interpolation{Parts: parts}
 }
 
 /*
@@ -444,15 +970,39 @@ func (parser *Parser) isAtEnd() bool {
 }
 
 func (parser *Parser) peek() Token {
+	parser.fill(parser.current)
 	return parser.tokens[parser.current]
 }
 
+// Pull tokens from the source until index is buffered
+func (parser *Parser) fill(index int) {
+
+	for parser.source != nil && len(parser.tokens) <= index {
+		parser.tokens = append(parser.tokens, parser.source.NextToken())
+	}
+}
+
+// Forget tokens before the previous one once a declaration is parsed
+func (parser *Parser) discard() {
+
+	if parser.source != nil && parser.current > 1 {
+		parser.tokens = parser.tokens[parser.current-1:]
+		parser.current = 1
+	}
+}
+
 func (parser *Parser) previous() Token {
 	return parser.tokens[parser.current-1]
 }
 
 func (parser *Parser) advance() Token {
 	if !parser.isAtEnd() {
+		switch parser.peek().TokenType {
+		case LEFT_BRACE:
+			parser.braces++
+		case RIGHT_BRACE:
+			parser.braces--
+		}
 		parser.current++
 	}
 
@@ -471,6 +1021,16 @@ func (parser *Parser) match(tokenTypes ...TokenType) bool {
 	return false
 }
 
+// Look one token past the current one
+func (parser *Parser) checkNext(tokenType TokenType) bool {
+
+	if parser.isAtEnd() {
+		return false
+	}
+	parser.fill(parser.current + 1)
+	return parser.tokens[parser.current+1].TokenType == tokenType
+}
+
 func (parser *Parser) check(tokenType TokenType) bool {
 
 	if parser.isAtEnd() {
@@ -484,6 +1044,40 @@ func (parser *Parser) consume(tokenType TokenType, message string) Token {
 		return parser.advance()
 	}
 
-	m := fmt.Sprintf("%s %s", parser.peek().TokenType, message)
-	panic(m)
+	panic(newParseError(parser.peek(), message, tokenType))
+}
+
+// Discard tokens until we are probably at the start of the next statement.
+// The } closing the enclosing block is left for block(), so a missing ;
+// before it doesn't also lose the block
+func (parser *Parser) synchronize() {
+
+	if parser.closesBlock() {
+		return
+	}
+	parser.advance()
+
+	for !parser.isAtEnd() {
+		if parser.previous().TokenType == SEMICOLON {
+			return
+		}
+
+		switch parser.peek().TokenType {
+		case CLASS, FUN, VAR, FOR, IF, WHILE, DO, PRINT, RETURN, MATCH, THROW, TRY:
+			return
+		case RIGHT_BRACE:
+			if parser.closesBlock() {
+				return
+			}
+		}
+
+		parser.advance()
+	}
+}
+
+// Whether the next token is the } of the innermost block, rather than one
+// closing a map, class or nested block opened since
+func (parser *Parser) closesBlock() bool {
+	return len(parser.blocks) > 0 && parser.check(RIGHT_BRACE) &&
+		parser.braces == parser.blocks[len(parser.blocks)-1]
 }
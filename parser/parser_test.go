@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/elliotthill/golox/language"
+	"github.com/elliotthill/golox/lexer"
+)
+
+// Source text for every registered binary operator
+var operatorLexemes = map[TokenType]string{
+	OR:            "or",
+	AND:           "and",
+	BANG_EQUAL:    "!=",
+	EQUAL_EQUAL:   "==",
+	GREATER:       ">",
+	GREATER_EQUAL: ">=",
+	LESS:          "<",
+	LESS_EQUAL:    "<=",
+	MINUS:         "-",
+	PLUS:          "+",
+	SLASH:         "/",
+	STAR:          "*",
+}
+
+// Print an expression fully parenthesized so grouping is visible
+func sexpr(expr AbstractExpression) string {
+
+	switch e := expr.(type) {
+	case Binary:
+		return "(" + e.Operator.Lexeme + " " + sexpr(e.Left) + " " + sexpr(e.Right) + ")"
+	case Logical:
+		return "(" + e.Operator.Lexeme + " " + sexpr(e.Left) + " " + sexpr(e.Right) + ")"
+	case Unary:
+		return "(" + e.Operator.Lexeme + " " + sexpr(e.Right) + ")"
+	case Grouping:
+		return "(group " + sexpr(e.Expression) + ")"
+	case Ternary:
+		return "(?: " + sexpr(e.Left) + " " + sexpr(e.Middle) + " " + sexpr(e.Right) + ")"
+	case Get:
+		return "(. " + sexpr(e.Object) + " " + e.Name.Lexeme + ")"
+	case Set:
+		return "(.= " + sexpr(e.Object) + " " + e.Name.Lexeme + " " + sexpr(e.Value) + ")"
+	case *This:
+		return "this"
+	case *Assign:
+		return "(= " + e.Name.Lexeme + " " + sexpr(e.Value) + ")"
+	case Call:
+		args := []string{sexpr(e.Callee)}
+		for _, arg := range e.Arguments {
+			args = append(args, sexpr(arg))
+		}
+		for _, arg := range e.Named {
+			args = append(args, arg.Name.Lexeme+":"+sexpr(arg.Value))
+		}
+		return "(call " + strings.Join(args, " ") + ")"
+	case *Variable:
+		return e.Name.Lexeme
+	case List:
+		elements := []string{"list"}
+		for _, element := range e.Elements {
+			elements = append(elements, sexpr(element))
+		}
+		return "(" + strings.Join(elements, " ") + ")"
+	case ParallelAssign:
+		parts := []string{"="}
+		for _, target := range e.Targets {
+			parts = append(parts, sexpr(target))
+		}
+		for _, value := range e.Values {
+			parts = append(parts, sexpr(value))
+		}
+		return "(" + strings.Join(parts, " ") + ")"
+	case Map:
+		entries := []string{"map"}
+		for i := range e.Keys {
+			entries = append(entries, sexpr(e.Keys[i])+":"+sexpr(e.Values[i]))
+		}
+		return "(" + strings.Join(entries, " ") + ")"
+	case Index:
+		return "([] " + sexpr(e.Object) + " " + sexpr(e.Index) + ")"
+	case IndexSet:
+		return "([]= " + sexpr(e.Object) + " " + sexpr(e.Index) + " " + sexpr(e.Value) + ")"
+	case Literal:
+		return fmt.Sprint(e.Value)
+	default:
+		return fmt.Sprintf("%T", e)
+	}
+}
+
+func parseExpression(t *testing.T, source string) string {
+
+	tokens, diagnostics := lexer.NewScanner(source + ";").Scan()
+	if len(diagnostics) > 0 {
+		t.Fatalf("%s: unexpected diagnostics %v", source, diagnostics)
+	}
+
+	statements, errors := NewParser(tokens).Parse()
+	if len(errors) > 0 {
+		t.Errorf("%s: unexpected parse errors %v", source, errors)
+		return ""
+	}
+	if len(statements) != 1 {
+		t.Errorf("%s: got %d statements, expected 1", source, len(statements))
+		return ""
+	}
+
+	statement, ok := statements[0].(Expression)
+	if !ok {
+		t.Errorf("%s: got %T, expected an expression statement", source, statements[0])
+		return ""
+	}
+	return sexpr(statement.Expression)
+}
+
+// a op1 b op2 c groups left unless op2 binds tighter
+func TestPrecedencePairs(t *testing.T) {
+
+	for first, firstOperator := range binaryOperators {
+		for second, secondOperator := range binaryOperators {
+
+			op1, ok1 := operatorLexemes[first]
+			op2, ok2 := operatorLexemes[second]
+			if !ok1 || !ok2 {
+				t.Fatalf("No lexeme for %s or %s, add it to operatorLexemes", first, second)
+			}
+
+			source := "a " + op1 + " b " + op2 + " c"
+			expected := "(" + op2 + " (" + op1 + " a b) c)"
+			if secondOperator.precedence > firstOperator.precedence {
+				expected = "(" + op1 + " a (" + op2 + " b c))"
+			}
+
+			if got := parseExpression(t, source); got != expected {
+				t.Errorf("%s: got %s, expected %s", source, got, expected)
+			}
+		}
+	}
+}
+
+type ExpressionTest struct {
+	source   string
+	expected string
+}
+
+func TestExpressions(t *testing.T) {
+
+	tests := []ExpressionTest{
+		{source: "a == b == c", expected: "(== (== a b) c)"},
+		{source: "x * y * z", expected: "(* (* x y) z)"},
+		{source: "a and b and c", expected: "(and (and a b) c)"},
+		{source: "a or b and c or d", expected: "(or (or a (and b c)) d)"},
+		{source: "1 < 2 + 3", expected: "(< 1 (+ 2 3))"},
+		{source: "10 - 2 - 3", expected: "(- (- 10 2) 3)"},
+		{source: "-a * -b", expected: "(* (- a) (- b))"},
+		{source: "!a == b", expected: "(== (! a) b)"},
+		{source: "(a + b) * c", expected: "(* (group (+ a b)) c)"},
+		{source: "f(a + b) * 2", expected: "(* (call f (+ a b)) 2)"},
+		{source: "a = b = c + 1", expected: "(= a (= b (+ c 1)))"},
+		{source: "a = b or c", expected: "(= a (or b c))"},
+		{source: "a.b.c", expected: "(. (. a b) c)"},
+		{source: "a.b(c).d", expected: "(. (call (. a b) c) d)"},
+		{source: "a.b = c.d = 1", expected: "(.= a b (.= c d 1))"},
+		{source: "f().x = -y", expected: "(.= (call f) x (- y))"},
+		{source: "[]", expected: "(list)"},
+		{source: "[1, a + b, [c],]", expected: "(list 1 (+ a b) (list c))"},
+		{source: "xs[i + 1][-1]", expected: "([] ([] xs (+ i 1)) (- 1))"},
+		{source: "xs[0] = ys[1] = 2", expected: "([]= xs 0 ([]= ys 1 2))"},
+		{source: "f()[0].a", expected: "(. ([] (call f) 0) a)"},
+		{source: "a, b = b, a", expected: "(= a b b a)"},
+		{source: "a.x, xs[0] = f(b, c), d ? 1 : 2", expected: "(= (. a x) ([] xs 0) (call f b c) (?: d 1 2))"},
+		{source: "render(doc, pretty: true, depth: a ? 1 : 2)", expected: "(call render doc pretty:true depth:(?: a 1 2))"},
+		{source: "m = {}", expected: "(= m (map))"},
+		{source: "m = {'a': 1, b + 1: [c],}", expected: "(= m (map a:1 (+ b 1):(list c)))"},
+		{source: "m = {kind: 1, (kind): 2}", expected: "(= m (map kind:1 (group kind):2))"},
+		{source: "f({'m': {}}['m'])", expected: "(call f ([] (map m:(map)) m))"},
+		{source: "a ? b : c", expected: "(?: a b c)"},
+		{source: "a ? b : c ? d : e", expected: "(?: a b (?: c d e))"},
+		{source: "a ? b ? c : d : e", expected: "(?: a (?: b c d) e)"},
+		{source: "a or b ? c and d : e == f", expected: "(?: (or a b) (and c d) (== e f))"},
+		{source: "x = a ? b : c", expected: "(= x (?: a b c))"},
+	}
+
+	for _, test := range tests {
+		if got := parseExpression(t, test.source); got != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.expected)
+		}
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+
+	sources := []string{"a ? b;", "a ? b : c = d;", "a ? : c;"}
+
+	for _, source := range sources {
+		tokens, _ := lexer.NewScanner(source).Scan()
+		if _, errors := NewParser(tokens).Parse(); len(errors) == 0 {
+			t.Errorf("%s: expected a parse error", source)
+		}
+	}
+}
+
+type ErrorTest struct {
+	source string
+	errors int
+}
+
+func TestLoopJumps(t *testing.T) {
+
+	tests := []ErrorTest{
+		{source: "while (true) { break; continue; }", errors: 0},
+		{source: "for (;;) if (true) { break; }", errors: 0},
+		{source: "break;", errors: 1},
+		{source: "{ continue; }", errors: 1},
+		{source: "if (true) break; else continue;", errors: 2},
+		{source: "while (true) { fun f() { break; } }", errors: 1},
+		{source: "while (true) { var f = fun (a) { continue; }; }", errors: 1},
+		{source: "fun f() { while (true) { break; } }", errors: 0},
+		{source: "a: while (true) { b: do { break a; continue b; } while (true); }", errors: 0},
+		{source: "a: for (;;) { break b; }", errors: 1},
+		{source: "a: for (;;) { a: while (true) {} }", errors: 1},
+		{source: "a: for (;;) {} b: while (true) { continue a; }", errors: 1},
+		{source: "a: while (true) { fun f() { a: while (true) { break a; } } }", errors: 0},
+		{source: "a: while (true) { fun f() { break a; } }", errors: 2},
+		{source: "a: print 1;", errors: 1},
+		{source: "do print 1; while (true)", errors: 1},
+	}
+
+	for _, test := range tests {
+		tokens, _ := lexer.NewScanner(test.source).Scan()
+		if _, errors := NewParser(tokens).Parse(); len(errors) != test.errors {
+			t.Errorf("%s: got errors %v, expected %d", test.source, errors, test.errors)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	. "github.com/elliotthill/golox/language"
+)
+
+// Binding power of binary operators, higher binds tighter
+type Precedence int
+
+const (
+	PREC_NONE Precedence = iota
+	PREC_OR
+	PREC_AND
+	PREC_EQUALITY
+	PREC_COMPARISON
+	PREC_TERM
+	PREC_FACTOR
+)
+
+// Builds the AST node for a parsed binary operator
+type infixBuilder func(left AbstractExpression, operator Token, right AbstractExpression) AbstractExpression
+
+type binaryOperator struct {
+	precedence       Precedence
+	rightAssociative bool
+	build            infixBuilder
+}
+
+var binaryOperators = map[TokenType]binaryOperator{}
+
+func init() {
+
+	registerBinaryOperator(OR, PREC_OR, false, logical)
+	registerBinaryOperator(AND, PREC_AND, false, logical)
+
+	registerBinaryOperator(BANG_EQUAL, PREC_EQUALITY, false, binary)
+	registerBinaryOperator(EQUAL_EQUAL, PREC_EQUALITY, false, binary)
+
+	registerBinaryOperator(GREATER, PREC_COMPARISON, false, binary)
+	registerBinaryOperator(GREATER_EQUAL, PREC_COMPARISON, false, binary)
+	registerBinaryOperator(LESS, PREC_COMPARISON, false, binary)
+	registerBinaryOperator(LESS_EQUAL, PREC_COMPARISON, false, binary)
+
+	registerBinaryOperator(MINUS, PREC_TERM, false, binary)
+	registerBinaryOperator(PLUS, PREC_TERM, false, binary)
+
+	registerBinaryOperator(SLASH, PREC_FACTOR, false, binary)
+	registerBinaryOperator(STAR, PREC_FACTOR, false, binary)
+}
+
+// Adding an operator only needs a token type and a row in the table
+func registerBinaryOperator(tokenType TokenType, precedence Precedence, rightAssociative bool, build infixBuilder) {
+
+	binaryOperators[tokenType] = binaryOperator{precedence: precedence, rightAssociative: rightAssociative, build: build}
+}
+
+func binary(left AbstractExpression, operator Token, right AbstractExpression) AbstractExpression {
+	return Binary{Left: left, Operator: operator, Right: right}
+}
+
+func logical(left AbstractExpression, operator Token, right AbstractExpression) AbstractExpression {
+	return Logical{Left: left, Operator: operator, Right: right}
+}
@@ -0,0 +1,23 @@
+package resolver
+
+import (
+	"fmt"
+
+	. "github.com/elliotthill/golox/language"
+)
+
+// A static error found while resolving, such as reading a local in its own initializer
+type ResolveError struct {
+	Message string
+	Line    int
+	Column  int
+	Token   Token
+}
+
+func (err ResolveError) Error() string {
+	return fmt.Sprintf("[line %d:%d] %s", err.Line, err.Column, err.Message)
+}
+
+func newResolveError(token Token, message string) ResolveError {
+	return ResolveError{Message: message, Line: token.Line, Column: token.Column, Token: token}
+}
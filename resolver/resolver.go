@@ -0,0 +1,580 @@
+package resolver
+
+import (
+	"fmt"
+
+	. "github.com/elliotthill/golox/language"
+)
+
+type functionType int
+
+const (
+	NO_FUNCTION functionType = iota
+	FUNCTION
+	METHOD
+	INITIALIZER
+)
+
+// Walks the parsed program before it runs, working out how many scopes out
+// each local variable lives so the interpreter can go straight to it
+type Resolver struct {
+	ExpressionVisitor
+	StatementVisitor
+	scopes          []map[string]bool //Innermost last, false until the initializer has run
+	globals         map[string]bool
+	locals          map[AbstractExpression]int
+	currentFunction functionType
+	errors          []ResolveError
+	warnings        []ResolveError //Suspicious but valid code, reported without stopping the run
+}
+
+// globals are names already defined by the interpreter, such as those from
+// earlier REPL lines
+func NewResolver(globals []string) *Resolver {
+
+	resolver := new(Resolver)
+	resolver.globals = make(map[string]bool)
+	resolver.locals = make(map[AbstractExpression]int)
+	resolver.currentFunction = NO_FUNCTION
+
+	for _, name := range globals {
+		resolver.globals[name] = true
+	}
+	return resolver
+}
+
+func (resolver *Resolver) Resolve(statements []AbstractStatement) (map[AbstractExpression]int, []ResolveError) {
+
+	//Globals may be used before their declaration, e.g. in a function body
+	for _, statement := range statements {
+		switch declaration := statement.(type) {
+		case Var:
+			resolver.globals[declaration.Name.Lexeme] = true
+		case Destructure:
+			for _, name := range declaration.Names {
+				resolver.globals[name.Lexeme] = true
+			}
+			if declaration.Rest != nil {
+				resolver.globals[declaration.Rest.Lexeme] = true
+			}
+		case Function:
+			resolver.globals[declaration.Name.Lexeme] = true
+		case Class:
+			resolver.globals[declaration.Name.Lexeme] = true
+		}
+	}
+
+	resolver.resolveStatements(statements)
+	return resolver.locals, resolver.errors
+}
+
+// Warnings found by the last call to Resolve
+func (resolver *Resolver) Warnings() []ResolveError {
+	return resolver.warnings
+}
+
+func (resolver *Resolver) resolveStatements(statements []AbstractStatement) {
+
+	for _, statement := range statements {
+		resolver.resolveStatement(statement)
+	}
+}
+
+func (resolver *Resolver) resolveStatement(statement AbstractStatement) {
+	statement.Accept(resolver)
+}
+
+func (resolver *Resolver) resolveExpression(expression AbstractExpression) {
+	expression.Accept(resolver)
+}
+
+/*
+* Statements
+ */
+func (resolver *Resolver) VisitBlockStatement(stmt Block) interface{} {
+
+	resolver.beginScope()
+	resolver.resolveStatements(stmt.Statements)
+	resolver.endScope()
+	return nil
+}
+
+func (resolver *Resolver) VisitClassStatement(stmt Class) interface{} {
+
+	resolver.declare(stmt.Name)
+	resolver.define(stmt.Name)
+
+	if stmt.Superclass != nil {
+		resolver.resolveExpression(stmt.Superclass)
+
+		resolver.beginScope()
+		resolver.peekScope()["super"] = true
+	}
+
+	resolver.beginScope()
+	resolver.peekScope()["this"] = true
+
+	for _, method := range stmt.Methods {
+		kind := METHOD
+		if method.Name.Lexeme == "init" {
+			kind = INITIALIZER
+		}
+		resolver.resolveFunction(method, kind)
+	}
+
+	resolver.endScope()
+
+	if stmt.Superclass != nil {
+		resolver.endScope()
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitExpressionStatement(stmt Expression) interface{} {
+	resolver.resolveExpression(stmt.Expression)
+	return nil
+}
+
+func (resolver *Resolver) VisitPrintStatement(stmt Print) interface{} {
+	resolver.resolveExpression(stmt.Expression)
+	return nil
+}
+
+func (resolver *Resolver) VisitVarStatement(stmt Var) interface{} {
+
+	resolver.declare(stmt.Name)
+	if stmt.Initializer != nil {
+		resolver.resolveExpression(stmt.Initializer)
+	}
+	resolver.define(stmt.Name)
+	return nil
+}
+
+func (resolver *Resolver) VisitDestructureStatement(stmt Destructure) interface{} {
+
+	names := stmt.Names
+	if stmt.Rest != nil {
+		names = append(names[:len(names):len(names)], *stmt.Rest)
+	}
+
+	for _, name := range names {
+		resolver.declare(name)
+	}
+	resolver.resolveExpression(stmt.Initializer)
+	for _, name := range names {
+		resolver.define(name)
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitThrowStatement(stmt Throw) interface{} {
+	resolver.resolveExpression(stmt.Value)
+	return nil
+}
+
+// Each clause is its own scope, the catch scope also holds the error name
+func (resolver *Resolver) VisitTryStatement(stmt Try) interface{} {
+
+	resolver.beginScope()
+	resolver.resolveStatements(stmt.Body)
+	resolver.endScope()
+
+	if stmt.Name != nil {
+		resolver.beginScope()
+		resolver.declare(*stmt.Name)
+		resolver.define(*stmt.Name)
+		resolver.resolveStatements(stmt.Catch)
+		resolver.endScope()
+	}
+
+	if stmt.Finally != nil {
+		resolver.beginScope()
+		resolver.resolveStatements(stmt.Finally)
+		resolver.endScope()
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitMatchStatement(stmt Match) interface{} {
+
+	resolver.resolveExpression(stmt.Value)
+
+	var catchAll *MatchArm = nil
+	for i, arm := range stmt.Arms {
+
+		if catchAll != nil {
+			resolver.warning(arm.Case, fmt.Sprintf("No value can reach this case, the case on line %d matches everything.", catchAll.Case.Line))
+		}
+
+		resolver.beginScope()
+		for _, pattern := range arm.Patterns {
+			if catchAll == nil && arm.Guard == nil && isIrrefutable(pattern) {
+				catchAll = &stmt.Arms[i]
+			}
+
+			names := patternNames(pattern)
+			if len(arm.Patterns) > 1 && len(names) > 0 {
+				resolver.error(names[0], "Can't bind names in a case with several patterns.")
+				continue
+			}
+			for _, name := range names {
+				resolver.declare(name)
+				resolver.define(name)
+			}
+		}
+
+		if arm.Guard != nil {
+			resolver.resolveExpression(arm.Guard)
+		}
+		resolver.resolveStatement(arm.Body)
+		resolver.endScope()
+	}
+
+	//Without a catch-all some values fall through every case and nothing runs
+	if catchAll == nil {
+		resolver.warning(stmt.Keyword, "Some values match no case, add 'case _' to handle them.")
+	}
+	return nil
+}
+
+// Names bound by a pattern, in order
+func patternNames(pattern AbstractPattern) []Token {
+
+	switch pattern := pattern.(type) {
+	case BindingPattern:
+		return []Token{pattern.Name}
+	case ListPattern:
+		names := []Token{}
+		for _, element := range pattern.Elements {
+			names = append(names, patternNames(element)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, *pattern.Rest)
+		}
+		return names
+	case MapPattern:
+		names := []Token{}
+		for _, value := range pattern.Values {
+			names = append(names, patternNames(value)...)
+		}
+		return names
+	default:
+		return nil
+	}
+}
+
+func isIrrefutable(pattern AbstractPattern) bool {
+
+	switch pattern.(type) {
+	case WildcardPattern, BindingPattern:
+		return true
+	default:
+		return false
+	}
+}
+
+func (resolver *Resolver) VisitIfStatement(stmt If) interface{} {
+
+	resolver.resolveExpression(stmt.Condition)
+	resolver.resolveStatement(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		resolver.resolveStatement(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitWhileStatement(stmt While) interface{} {
+
+	resolver.resolveExpression(stmt.Condition)
+	resolver.resolveStatement(stmt.Body)
+	if stmt.Increment != nil {
+		resolver.resolveExpression(stmt.Increment)
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitDoWhileStatement(stmt DoWhile) interface{} {
+
+	resolver.resolveStatement(stmt.Body)
+	resolver.resolveExpression(stmt.Condition)
+	return nil
+}
+
+func (resolver *Resolver) VisitBreakStatement(stmt Break) interface{} {
+	return nil
+}
+
+func (resolver *Resolver) VisitContinueStatement(stmt Continue) interface{} {
+	return nil
+}
+
+func (resolver *Resolver) VisitReturnStatement(stmt Return) interface{} {
+
+	if resolver.currentFunction == NO_FUNCTION {
+		resolver.error(stmt.Keyword, "Can't return from top-level code.")
+	}
+
+	if stmt.Value != nil {
+		if resolver.currentFunction == INITIALIZER {
+			resolver.error(stmt.Keyword, "Can't return a value from an initializer.")
+		}
+		resolver.resolveExpression(stmt.Value)
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitFunctionStatement(stmt Function) interface{} {
+
+	//Defined before the body so it can call itself
+	resolver.declare(stmt.Name)
+	resolver.define(stmt.Name)
+
+	resolver.resolveFunction(stmt, FUNCTION)
+	return nil
+}
+
+func (resolver *Resolver) resolveFunction(function Function, kind functionType) {
+
+	//Defaults are evaluated in the closure, not alongside the parameters
+	for _, value := range function.Defaults {
+		if value != nil {
+			resolver.resolveExpression(value)
+		}
+	}
+
+	enclosingFunction := resolver.currentFunction
+	resolver.currentFunction = kind
+
+	resolver.beginScope()
+	for _, param := range function.Params {
+		resolver.declare(param)
+		resolver.define(param)
+	}
+	if function.Rest != nil {
+		resolver.declare(*function.Rest)
+		resolver.define(*function.Rest)
+	}
+	resolver.resolveStatements(function.Body)
+	resolver.endScope()
+
+	resolver.currentFunction = enclosingFunction
+}
+
+/*
+* Expressions
+ */
+func (resolver *Resolver) VisitAssignExpression(expr *Assign) interface{} {
+
+	resolver.resolveExpression(expr.Value)
+	resolver.resolveLocal(expr, expr.Name)
+	return nil
+}
+
+func (resolver *Resolver) VisitParallelAssignExpression(expr ParallelAssign) interface{} {
+
+	for _, value := range expr.Values {
+		resolver.resolveExpression(value)
+	}
+
+	for _, target := range expr.Targets {
+		switch target := target.(type) {
+		case *Variable:
+			resolver.resolveLocal(target, target.Name)
+		case Get:
+			resolver.resolveExpression(target.Object)
+		case Index:
+			resolver.resolveExpression(target.Object)
+			resolver.resolveExpression(target.Index)
+		}
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitBinaryExpression(expr Binary) interface{} {
+
+	resolver.resolveExpression(expr.Left)
+	resolver.resolveExpression(expr.Right)
+	return nil
+}
+
+func (resolver *Resolver) VisitGroupingExpression(expr Grouping) interface{} {
+	resolver.resolveExpression(expr.Expression)
+	return nil
+}
+
+func (resolver *Resolver) VisitLiteralExpression(expr Literal) interface{} {
+	return nil
+}
+
+func (resolver *Resolver) VisitLogicalExpression(expr Logical) interface{} {
+
+	resolver.resolveExpression(expr.Left)
+	resolver.resolveExpression(expr.Right)
+	return nil
+}
+
+func (resolver *Resolver) VisitTernaryExpression(expr Ternary) interface{} {
+
+	resolver.resolveExpression(expr.Left)
+	resolver.resolveExpression(expr.Middle)
+	resolver.resolveExpression(expr.Right)
+	return nil
+}
+
+func (resolver *Resolver) VisitUnaryExpression(expr Unary) interface{} {
+	resolver.resolveExpression(expr.Right)
+	return nil
+}
+
+func (resolver *Resolver) VisitVariableExpression(expr *Variable) interface{} {
+
+	if len(resolver.scopes) > 0 {
+		if defined, ok := resolver.peekScope()[expr.Name.Lexeme]; ok && !defined {
+			resolver.error(expr.Name, "Can't read local variable in its own initializer.")
+		}
+	}
+
+	resolver.resolveLocal(expr, expr.Name)
+	return nil
+}
+
+func (resolver *Resolver) VisitCallExpression(expr Call) interface{} {
+
+	resolver.resolveExpression(expr.Callee)
+	for _, argument := range expr.Arguments {
+		resolver.resolveExpression(argument)
+	}
+	for _, argument := range expr.Named {
+		resolver.resolveExpression(argument.Value)
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitFunctionExpression(expr FunctionExpression) interface{} {
+	resolver.resolveFunction(Function{Params: expr.Params, Defaults: expr.Defaults, Rest: expr.Rest, Body: expr.Body}, FUNCTION)
+	return nil
+}
+
+func (resolver *Resolver) VisitInterpolationExpression(expr Interpolation) interface{} {
+
+	for _, part := range expr.Parts {
+		resolver.resolveExpression(part)
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitGetExpression(expr Get) interface{} {
+	resolver.resolveExpression(expr.Object)
+	return nil
+}
+
+func (resolver *Resolver) VisitSetExpression(expr Set) interface{} {
+
+	resolver.resolveExpression(expr.Value)
+	resolver.resolveExpression(expr.Object)
+	return nil
+}
+
+func (resolver *Resolver) VisitThisExpression(expr *This) interface{} {
+	resolver.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+func (resolver *Resolver) VisitSuperExpression(expr *Super) interface{} {
+	resolver.resolveLocal(expr, expr.Keyword)
+	return nil
+}
+
+func (resolver *Resolver) VisitListExpression(expr List) interface{} {
+
+	for _, element := range expr.Elements {
+		resolver.resolveExpression(element)
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitMapExpression(expr Map) interface{} {
+
+	for i := range expr.Keys {
+		resolver.resolveExpression(expr.Keys[i])
+		resolver.resolveExpression(expr.Values[i])
+	}
+	return nil
+}
+
+func (resolver *Resolver) VisitIndexExpression(expr Index) interface{} {
+
+	resolver.resolveExpression(expr.Object)
+	resolver.resolveExpression(expr.Index)
+	return nil
+}
+
+func (resolver *Resolver) VisitIndexSetExpression(expr IndexSet) interface{} {
+
+	resolver.resolveExpression(expr.Object)
+	resolver.resolveExpression(expr.Index)
+	resolver.resolveExpression(expr.Value)
+	return nil
+}
+
+/*
+* Scopes
+ */
+func (resolver *Resolver) beginScope() {
+	resolver.scopes = append(resolver.scopes, make(map[string]bool))
+}
+
+func (resolver *Resolver) endScope() {
+	resolver.scopes = resolver.scopes[:len(resolver.scopes)-1]
+}
+
+func (resolver *Resolver) peekScope() map[string]bool {
+	return resolver.scopes[len(resolver.scopes)-1]
+}
+
+// Globals are late bound and may be redeclared, so only locals are tracked
+func (resolver *Resolver) declare(name Token) {
+
+	if len(resolver.scopes) == 0 {
+		resolver.globals[name.Lexeme] = true
+		return
+	}
+
+	scope := resolver.peekScope()
+	if _, ok := scope[name.Lexeme]; ok {
+		resolver.error(name, "Already a variable named '"+name.Lexeme+"' in this scope.")
+	}
+	scope[name.Lexeme] = false
+}
+
+func (resolver *Resolver) define(name Token) {
+
+	if len(resolver.scopes) == 0 {
+		return
+	}
+	resolver.peekScope()[name.Lexeme] = true
+}
+
+// Record how many scopes out name was declared, anything not found in a
+// local scope must be a global
+func (resolver *Resolver) resolveLocal(expr AbstractExpression, name Token) {
+
+	for i := len(resolver.scopes) - 1; i >= 0; i-- {
+		if _, ok := resolver.scopes[i][name.Lexeme]; ok {
+			resolver.locals[expr] = len(resolver.scopes) - 1 - i
+			return
+		}
+	}
+
+	if !resolver.globals[name.Lexeme] {
+		resolver.error(name, "Undefined variable '"+name.Lexeme+"'.")
+	}
+}
+
+func (resolver *Resolver) error(token Token, message string) {
+	resolver.errors = append(resolver.errors, newResolveError(token, message))
+}
+
+func (resolver *Resolver) warning(token Token, message string) {
+	resolver.warnings = append(resolver.warnings, newResolveError(token, message))
+}